@@ -0,0 +1,560 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Distributes coins among the active members
+func runCoins(args []string, stdout io.Writer) error {
+	fs := newFlagSet("coins")
+	coins := addCoinFlags(fs)
+	reason := fs.String("reason", "", "why the coins were awarded")
+	split := fs.String("split", "coin", "how to split the coins: coin, value or exact")
+	sharing := addMembersFlag(fs)
+	treasuryPercent := fs.Int("treasury", 0, "percentage of each coin type set aside for the party treasury")
+	settle := fs.Bool("settle-debts", false, "pay back loans out of the borrowers' shares")
+	var claims claimList
+	fs.Var(&claims, "claim", "NAME=ITEM[:QTY], a member taking an item from the loot pool as part of their share, can be repeated")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: coins takes no arguments, got %q", errUsage, positional[0])
+	}
+	if *treasuryPercent < 0 || *treasuryPercent > 100 {
+		return fmt.Errorf("%w: --treasury must be between 0 and 100", errUsage)
+	}
+	money, err := coinsFromFlags(coins)
+	if err != nil {
+		return err
+	}
+	opts := commands.CoinOptions{TreasuryPercent: *treasuryPercent, SettleDebts: *settle}
+	switch *split {
+	case "coin":
+		opts.Strategy = commands.SplitByCoin
+	case "value":
+		opts.Strategy = commands.SplitByValue
+	case "exact":
+		opts.Strategy = commands.SplitExact
+	default:
+		return fmt.Errorf("%w: unknown split %q, expected coin, value or exact", errUsage, *split)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	if len(party.ActiveMembers) == 0 {
+		return fmt.Errorf("there are no active members to distribute coins to")
+	}
+	if opts.Participants, err = resolveParticipants(&party, *sharing); err != nil {
+		return err
+	}
+
+	var plan commands.CoinPlan
+	if len(claims) > 0 {
+		itemClaims, err := resolveClaims(&party, claims)
+		if err != nil {
+			return err
+		}
+		if plan, err = commands.PlanAllocation(&party, money, opts, itemClaims); err != nil {
+			return err
+		}
+	} else {
+		plan = commands.PlanCoinDistribution(&party, money, opts)
+	}
+	commands.Record(&history, &party, models.CoinAward)
+	tx := commands.ApplyCoinPlan(&party, plan)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(slices.Concat([]string{"Name"}, models.CoinOrder), "\t"))
+	for _, share := range plan.Shares {
+		row := []string{share.Name}
+		for _, coinType := range models.CoinOrder {
+			cell := strconv.Itoa(share.Coins[coinType])
+			if extra := share.Extra[coinType]; extra > 0 {
+				cell += fmt.Sprintf(" (+%d)", extra)
+			}
+			row = append(row, cell)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(plan.Treasury) > 0 {
+		fmt.Fprintf(stdout, "%s put in the treasury\n", formatCoins(plan.Treasury))
+	}
+	for _, e := range plan.Exchanges {
+		fmt.Fprintln(stdout, e)
+	}
+	for _, s := range plan.Settlements {
+		fmt.Fprintf(stdout, "%s paid back %s to %s\n", s.Borrower, formatCoins(s.Coins), s.Lender)
+	}
+	if plan.ClaimsItems() {
+		for _, share := range plan.Shares {
+			fmt.Fprintln(stdout, describeAllocation(share))
+		}
+	}
+	return nil
+}
+
+// Distributes experience among the active members
+func runXP(args []string, stdout io.Writer) error {
+	fs := newFlagSet("xp")
+	reason := fs.String("reason", "", "why the experience was awarded")
+	encounter := fs.String("encounter", "", "award the XP for defeating monsters, as CR:count, ...")
+	multiplied := fs.Bool("apply-multiplier", false, "award the encounter's XP after the group size multiplier")
+	sharing := addMembersFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	var monsters []commands.MonsterGroup
+	xp := 0
+	if *encounter != "" {
+		if len(positional) != 0 {
+			return fmt.Errorf("%w: xp takes either an amount or --encounter, not both", errUsage)
+		}
+		if monsters, err = commands.ParseMonsters(*encounter); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+	} else {
+		if *multiplied {
+			return fmt.Errorf("%w: --apply-multiplier needs --encounter", errUsage)
+		}
+		if len(positional) != 1 {
+			return fmt.Errorf("%w: xp takes exactly one amount", errUsage)
+		}
+		xp, err = strconv.Atoi(positional[0])
+		if err != nil || xp < 0 {
+			return fmt.Errorf("%w: invalid experience amount %q", errUsage, positional[0])
+		}
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	if len(party.ActiveMembers) == 0 {
+		return fmt.Errorf("there are no active members to distribute experience to")
+	}
+	ids, err := resolveParticipants(&party, *sharing)
+	if err != nil {
+		return err
+	}
+	if monsters != nil {
+		e := commands.PlanEncounter(&party, monsters, ids)
+		xp = e.Award(*multiplied)
+		fmt.Fprintf(stdout, "Encounter: %d XP, x%g for %d XP adjusted (%s)\n", e.BaseXP, e.Multiplier, e.AdjustedXP, e.Difficulty)
+	}
+
+	commands.Record(&history, &party, models.XPAward)
+	tx, levelUps := commands.DistributeExperienceTo(&party, xp, ids)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	for _, member := range party.ActiveMembers {
+		if ids == nil || slices.Contains(ids, member.ID) {
+			fmt.Fprintf(stdout, "%s: %d XP (Level %d)\n", member.Name, member.XP, member.Level)
+		}
+	}
+	for _, e := range levelUps {
+		fmt.Fprintf(stdout, "%s went up from Level %d to Level %d!\n", e.Member, e.From, e.To)
+		for _, change := range e.Changes() {
+			fmt.Fprintf(stdout, "  %s\n", change)
+		}
+	}
+	return nil
+}
+
+// Adds, edits, deletes, activates or deactivates a member
+func runMember(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: member needs one of add, edit, delete, activate or deactivate", errUsage)
+	}
+
+	switch args[0] {
+	case "add":
+		return runMemberAdd(args[1:], stdout)
+	case "edit":
+		return runMemberEdit(args[1:], stdout)
+	case "delete":
+		return runMemberDelete(args[1:], stdout)
+	case "activate":
+		return runMemberGroup(args[1:], stdout, models.ActiveGroup)
+	case "deactivate":
+		return runMemberGroup(args[1:], stdout, models.InactiveGroup)
+	default:
+		return fmt.Errorf("%w: unknown member command %q", errUsage, args[0])
+	}
+}
+
+func runMemberAdd(args []string, stdout io.Writer) error {
+	fs := newFlagSet("member add")
+	xp := fs.Int("xp", 0, "starting experience")
+	share := fs.Float64("share", models.FullShare, "share weight, e.g. 0.5 for a half share")
+	coins := addCoinFlags(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] == "" {
+		return fmt.Errorf("%w: member add takes exactly one name", errUsage)
+	}
+	if *xp < 0 {
+		return fmt.Errorf("%w: experience can't be negative", errUsage)
+	}
+	if *share < models.MinShare {
+		return fmt.Errorf("%w: share must be at least %g", errUsage, models.MinShare)
+	}
+	money, err := coinsFromFlags(coins)
+	if err != nil {
+		return err
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+
+	commands.Record(&history, &party, models.MemberAdded)
+	tx := commands.AddMember(&party, positional[0], *xp, money)
+	added := &party.ActiveMembers[len(party.ActiveMembers)-1]
+	added.Share = *share
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Added %s (Level %d)\n", added.Name, added.Level)
+	return nil
+}
+
+// Changes only the fields whose flags were given
+func runMemberEdit(args []string, stdout io.Writer) error {
+	fs := newFlagSet("member edit")
+	name := fs.String("name", "", "new name")
+	xp := fs.Int("xp", 0, "new experience total")
+	share := fs.Float64("share", models.FullShare, "new share weight")
+	coins := addCoinFlags(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: member edit takes exactly one member name or ID", errUsage)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[0])
+	if err != nil {
+		return err
+	}
+
+	edit := commands.MemberEdit{Name: member.Name, XP: member.XP, Share: member.Share, Coins: maps.Clone(member.Coins)}
+	if edit.Coins == nil {
+		edit.Coins = make(map[string]int)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			edit.Name = *name
+		case "xp":
+			edit.XP = *xp
+		case "share":
+			edit.Share = *share
+		default:
+			for _, c := range models.CurrentCurrency.Coins {
+				if c.Abbr == f.Name {
+					edit.Coins[c.Name] = *coins[c.Name]
+				}
+			}
+		}
+	})
+
+	before := party.Clone()
+	tx, err := commands.EditMember(&party, member.ID, edit)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	commands.Record(&history, &before, models.MemberEdited)
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Saved %s\n", edit.Name)
+	return nil
+}
+
+// Removes a member for good, splitting or discarding their wallet
+func runMemberDelete(args []string, stdout io.Writer) error {
+	fs := newFlagSet("member delete")
+	walletFlag := fs.String("wallet", "split", "what happens to the member's coins: split, treasury or discard")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: member delete takes exactly one member name or ID", errUsage)
+	}
+	var wallet int
+	switch *walletFlag {
+	case "split":
+		wallet = commands.SplitWallet
+	case "treasury":
+		wallet = commands.TreasuryWallet
+	case "discard":
+		wallet = commands.DiscardWallet
+	default:
+		return fmt.Errorf("%w: unknown wallet option %q, expected split, treasury or discard", errUsage, *walletFlag)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[0])
+	if err != nil {
+		return err
+	}
+
+	before := party.Clone()
+	tx, err := commands.DeleteMember(&party, member.ID, wallet)
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.MemberRemoved)
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Deleted %s\n", member.Name)
+	return nil
+}
+
+func runMemberGroup(args []string, stdout io.Writer, dstName string) error {
+	fs := newFlagSet("member")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: expected exactly one member name or ID", errUsage)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[0])
+	if err != nil {
+		return err
+	}
+	dst := party.ActiveMembers
+	if dstName == models.InactiveGroup {
+		dst = party.InactiveMembers
+	}
+	if commands.FindMember(dst, member.ID) >= 0 {
+		return fmt.Errorf("%s is already %s", member.Name, strings.ToLower(dstName))
+	}
+
+	commands.Record(&history, &party, models.GroupChange)
+	tx, err := commands.ChangeMemberGroup(&party, member.ID, dstName)
+	if err != nil {
+		return err
+	}
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s is now %s\n", member.Name, strings.ToLower(dstName))
+	return nil
+}
+
+// Pays for a purchase from a member's wallet
+func runSpend(args []string, stdout io.Writer) error {
+	fs := newFlagSet("spend")
+	coins := addCoinFlags(fs)
+	reason := fs.String("reason", "", "what the coins were spent on")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: spend takes exactly one member name or ID", errUsage)
+	}
+	price, err := coinsFromFlags(coins)
+	if err != nil {
+		return err
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[0])
+	if err != nil {
+		return err
+	}
+
+	before := party.Clone()
+	tx, err := commands.Spend(&party, member.ID, price)
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.Purchase)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	paid, change := make(map[string]int), make(map[string]int)
+	for coinType, diff := range tx.Entries[0].Coins {
+		if diff < 0 {
+			paid[coinType] = -diff
+		} else {
+			change[coinType] = diff
+		}
+	}
+	fmt.Fprintf(stdout, "%s paid %s", member.Name, formatCoins(paid))
+	if len(change) > 0 {
+		fmt.Fprintf(stdout, " and got %s back", formatCoins(change))
+	}
+	fmt.Fprintln(stdout)
+	return nil
+}
+
+// Finds a member by ID, or by name if the name is unique
+func resolveMember(party *models.Party, nameOrID string) (models.Member, error) {
+	for _, m := range slices.Concat(party.ActiveMembers, party.InactiveMembers) {
+		if m.ID == nameOrID {
+			return m, nil
+		}
+	}
+
+	found := commands.FindMembersByName(party, nameOrID)
+	switch len(found) {
+	case 0:
+		return models.Member{}, fmt.Errorf("no member named %q", nameOrID)
+	case 1:
+		return found[0], nil
+	default:
+		var ids []string
+		for _, m := range found {
+			ids = append(ids, m.ID)
+		}
+		return models.Member{}, fmt.Errorf("more than one member is named %q, use one of their IDs instead: %s", nameOrID, strings.Join(ids, ", "))
+	}
+}
+
+// Adds the --members flag to a command that hands out an award
+func addMembersFlag(fs *flag.FlagSet) *string {
+	return fs.String("members", "", "comma separated names or IDs of the active members sharing the award, everyone if empty")
+}
+
+// Resolves the value of --members to member IDs. Returns nil if it's empty.
+func resolveParticipants(party *models.Party, list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var ids []string
+	for _, nameOrID := range strings.Split(list, ",") {
+		member, err := resolveMember(party, strings.TrimSpace(nameOrID))
+		if err != nil {
+			return nil, err
+		}
+		if commands.FindMember(party.ActiveMembers, member.ID) < 0 {
+			return nil, fmt.Errorf("%s isn't active, activate them first to include them", member.Name)
+		}
+		ids = append(ids, member.ID)
+	}
+	return ids, nil
+}
+
+// Prints the party
+func runShow(args []string, stdout io.Writer) error {
+	fs := newFlagSet("show")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: show takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	party, _, err := load()
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, newPartyReport(party))
+}
+
+// Prints every transaction in the ledger
+func runLedger(args []string, stdout io.Writer) error {
+	fs := newFlagSet("ledger")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: ledger takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	ledger, err := storage.LoadLedger()
+	if err != nil {
+		return fmt.Errorf("loading ledger: %w", err)
+	}
+	return writeReport(stdout, *format, newLedgerReport(ledger))
+}
+
+// Restores the party from its most recent valid backup
+func runRestore(args []string, stdout io.Writer) error {
+	fs := newFlagSet("restore")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: restore takes no arguments, got %q", errUsage, positional[0])
+	}
+
+	backup, _, err := storage.LatestValidBackup()
+	if err != nil {
+		return err
+	}
+	if err := storage.RestoreBackup(backup); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Restored backup from %s\n", backup.Created.Format(time.DateTime))
+	return nil
+}
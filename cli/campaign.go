@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Lists, creates, renames, archives or switches campaigns
+func runCampaign(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: campaign needs one of list, create, rename, archive, unarchive, use or leveling", errUsage)
+	}
+
+	if args[0] == "list" {
+		return runCampaignList(args[1:], stdout)
+	}
+
+	fs := newFlagSet("campaign " + args[0])
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	expected := 1
+	if args[0] == "rename" || args[0] == "leveling" {
+		expected = 2
+	}
+	if len(positional) != expected {
+		return fmt.Errorf("%w: campaign %s takes %d name(s)", errUsage, args[0], expected)
+	}
+	name := positional[0]
+
+	switch args[0] {
+	case "create":
+		if err := storage.CreateCampaign(name); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created campaign %s\n", name)
+	case "rename":
+		if err := storage.RenameCampaign(name, positional[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Renamed campaign %s to %s\n", name, positional[1])
+	case "archive", "unarchive":
+		if err := requireCampaign(name); err != nil {
+			return err
+		}
+		if err := storage.SetArchived(name, args[0] == "archive"); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Campaign %s %sd\n", name, args[0])
+	case "use":
+		if err := requireCampaign(name); err != nil {
+			return err
+		}
+		if err := storage.SetLastCampaign(name); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Now using campaign %s\n", name)
+	case "leveling":
+		if err := requireCampaign(name); err != nil {
+			return err
+		}
+		leveling, ok := levelingNames[positional[1]]
+		if !ok {
+			return fmt.Errorf("%w: unknown leveling %q, expected xp or milestone", errUsage, positional[1])
+		}
+		if err := storage.SetLeveling(name, leveling); err != nil {
+			return err
+		}
+		// Milestone levels aren't backed by XP, so work levels out again like a new XP table
+		tx, err := storage.UpdateCampaignParty(name, func(p *models.Party) models.Transaction {
+			tx := commands.UpdateLevels(p)
+			tx.Reason = "Leveling changed to " + positional[1]
+			return tx
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Campaign %s now uses %s leveling\n", name, positional[1])
+		for _, e := range tx.Entries {
+			fmt.Fprintf(stdout, "%s is now Level %d\n", e.Member, e.Level)
+		}
+	default:
+		return fmt.Errorf("%w: unknown campaign command %q", errUsage, args[0])
+	}
+	return nil
+}
+
+func runCampaignList(args []string, stdout io.Writer) error {
+	fs := newFlagSet("campaign list")
+	all := fs.Bool("all", false, "include archived campaigns")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: campaign list takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	campaigns, err := storage.ListCampaigns()
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, newCampaignReport(campaigns, *all))
+}
+
+// Returns an error if the named campaign hasn't been created
+func requireCampaign(name string) error {
+	campaigns, err := storage.ListCampaigns()
+	if err != nil {
+		return err
+	}
+	for _, c := range campaigns {
+		if c.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("campaign %q doesn't exist", name)
+}
+
+// The leveling each campaign leveling argument stands for
+var levelingNames = map[string]string{"xp": models.XPLeveling, "milestone": models.MilestoneLeveling}
+
+type campaignReport struct {
+	Name     string    `json:"name"`
+	Current  bool      `json:"current"`
+	Archived bool      `json:"archived"`
+	Created  time.Time `json:"created"`
+	Leveling string    `json:"leveling"`
+}
+
+// Builds the campaign list report, leaving out archived campaigns unless all is set
+func newCampaignReport(campaigns []models.Campaign, all bool) report {
+	reports := []campaignReport{}
+	r := report{header: []string{"Name", "Current", "Archived", "Created", "Leveling"}}
+	for _, c := range campaigns {
+		if c.Archived && !all {
+			continue
+		}
+		cr := campaignReport{Name: c.Name, Current: c.Name == storage.CurrentCampaign(), Archived: c.Archived, Created: c.Created, Leveling: "xp"}
+		if c.Leveling == models.MilestoneLeveling {
+			cr.Leveling = models.MilestoneLeveling
+		}
+		reports = append(reports, cr)
+		r.rows = append(r.rows, []string{cr.Name, strconv.FormatBool(cr.Current), strconv.FormatBool(cr.Archived), cr.Created.Format(time.DateOnly), cr.Leveling})
+	}
+	r.data = reports
+	return r
+}
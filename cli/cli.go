@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes returned by Run
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: dndgoldtracker [--campaign NAME] [command] [arguments]
+
+Run without a command to open the interactive tracker.
+--campaign picks an existing campaign to use, otherwise the last one switched to is used.
+
+Commands:
+  coins [--pp N] [--gp N] [--ep N] [--sp N] [--cp N] [--split coin|value|exact] [--members NAME,...]
+        [--treasury PERCENT] [--settle-debts] [--claim NAME=ITEM[:QTY]]... [--reason TEXT]
+        distribute coins among the active members, either splitting each coin type
+        on its own, giving everyone the same total value, or splitting each coin type
+        exactly by breaking leftovers into smaller coins.
+        --treasury sets aside that percentage of each coin type for the party treasury first.
+        --settle-debts pays back loans out of the borrowers' shares.
+        --claim has a member take an item from the loot pool as part of their share,
+        with the coins split so everyone's items and coins are worth as close to the same as possible
+  xp AMOUNT [--members NAME,...] [--reason TEXT]
+  xp --encounter CR:COUNT,... [--apply-multiplier] [--members NAME,...] [--reason TEXT]
+        distribute experience among the active members, or the XP for the monsters
+        of an encounter along with how hard it was for the party.
+        --apply-multiplier awards the XP after the multiplier for the number of monsters
+        --members limits either award to some of the active members
+  member add NAME [--xp N] [--share N] [--pp N] [--gp N] [--ep N] [--sp N] [--cp N]
+        add a new active member, --share 0.5 gives them a half share of coins and XP
+  member edit NAME|ID [--name NEW_NAME] [--xp N] [--share N] [--pp N] [--gp N] [--ep N] [--sp N] [--cp N]
+        change a member's name, XP total, share or coins, leaving anything not given alone
+  member delete NAME|ID [--wallet split|treasury|discard]
+        remove a member for good, splitting their coins among the active members,
+        putting them in the treasury or discarding them. Their items go back to the loot pool
+  member activate NAME|ID
+  member deactivate NAME|ID
+        move a member between the active and inactive groups,
+        use the ID from show when two members share a name
+  spend NAME|ID [--pp N] [--gp N] [--ep N] [--sp N] [--cp N] [--reason TEXT]
+        pay a price from a member's wallet, taking change when the exact coins aren't there
+  treasury deposit [--pp N] [--gp N] [--ep N] [--sp N] [--cp N] [--reason TEXT]
+  treasury withdraw NAME|ID [--pp N] [--gp N] [--ep N] [--sp N] [--cp N] [--reason TEXT]
+        move coins into the party treasury, or out of it to a member
+  transfer FROM TO [--pp N] [--gp N] [--ep N] [--sp N] [--cp N] [--loan|--repay] [--reason TEXT]
+        give coins from one member to another, --loan records that TO owes them back
+        and --repay takes them off what FROM owes TO
+  debts [--format table|json|csv]
+        print what members owe each other
+  loot add NAME [--qty N] [--value N] [--weight LB] [--notes TEXT] [--reason TEXT]
+        put a newly found item in the party loot pool, valued in the campaign's standard coin (gp in 5e)
+  loot give ITEM NAME|ID [--qty N] [--reason TEXT]
+  loot return ITEM NAME|ID [--qty N] [--reason TEXT]
+        move an item from the loot pool to a member's inventory or back, the whole stack unless --qty is given
+  loot attune ITEM NAME|ID [--end]
+        attune a member to one of their items, at most 3 at once, or end the attunement
+  loot sell ITEM [--from NAME|ID] [--qty N] [--percent N] [--keep] [--reason TEXT]
+        sell an item from the loot pool, or a member's inventory with --from, for a percentage
+        of its value (50 by default). The money is split among the active members,
+        or kept by the seller with --keep
+  loot list [--format table|json|csv]
+        print the loot pool and every member's inventory
+  loot sales [--format table|json|csv]
+        print every item the party has sold and where the money went
+  level up [--members NAME,...] [--session N] [--reason TEXT]
+  level set NAME|ID LEVEL [--session N] [--reason TEXT]
+        in a campaign using milestone leveling, raise the active members a level
+        or put one member at a level, noting the session it happened in
+  level history [--format table|json|csv]
+        print every level reached by milestone
+  xp-table list [--format table|json|csv]
+  xp-table show [--format table|json|csv]
+  xp-table use NAME|FILE
+        list the built in XP tables (5e, pf1-slow, pf1-medium, pf1-fast, homebrew-30),
+        print the XP needed for each level in the campaign, or switch the campaign
+        to a built in table or one read from a JSON file like {"Name": "Gritty", "Thresholds": [0, 1000, 3000]}
+  currency list [--format table|json|csv]
+  currency show [--format table|json|csv]
+  currency use NAME|FILE
+        list the built in currencies (5e, gsc), print the campaign's coins, or switch the campaign
+        to a built in currency or one read from a JSON file. The coin flags of every command
+        (--pp, --gp, ...) follow the abbreviations of the campaign's coins.
+        Coins anyone holds have to keep their value, and switching clears the undo history
+  show [--format table|json|csv]
+        print the party and its treasury
+  ledger [--format table|json|csv]
+        print every recorded coin, XP, item and membership change
+  restore
+        replace the party with its most recent valid backup
+  campaign list [--all] [--format table|json|csv]
+  campaign create NAME
+  campaign rename NAME NEW_NAME
+  campaign archive NAME
+  campaign unarchive NAME
+  campaign use NAME
+        manage campaigns, use switches the campaign opened by default
+  campaign leveling NAME xp|milestone
+        level characters by their XP, or directly with level up and level set
+`
+
+// Returned for mistakes in how a command was called
+var errUsage = errors.New("usage")
+
+// Flag names used by commands, which coin abbreviations can't take
+var reservedFlags = []string{"all", "claim", "encounter", "end", "format", "from", "h", "help", "keep", "loan", "members", "name", "notes",
+	"percent", "qty", "reason", "repay", "session", "share", "split", "treasury", "value", "wallet", "weight", "xp"}
+
+// Run executes a single non-interactive command and returns the process exit code
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "coins":
+		err = runCoins(args[1:], stdout)
+	case "xp":
+		err = runXP(args[1:], stdout)
+	case "member":
+		err = runMember(args[1:], stdout)
+	case "show":
+		err = runShow(args[1:], stdout)
+	case "ledger":
+		err = runLedger(args[1:], stdout)
+	case "spend":
+		err = runSpend(args[1:], stdout)
+	case "treasury":
+		err = runTreasury(args[1:], stdout)
+	case "transfer":
+		err = runTransfer(args[1:], stdout)
+	case "debts":
+		err = runDebts(args[1:], stdout)
+	case "loot":
+		err = runLoot(args[1:], stdout)
+	case "level":
+		err = runLevel(args[1:], stdout)
+	case "xp-table":
+		err = runXPTable(args[1:], stdout)
+	case "currency":
+		err = runCurrency(args[1:], stdout)
+	case "campaign":
+		err = runCampaign(args[1:], stdout)
+	case "restore":
+		err = runRestore(args[1:], stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(stderr, "Error:", strings.TrimPrefix(err.Error(), errUsage.Error()+": "))
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	default:
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+}
+
+// PrintUsage describes every command
+func PrintUsage(w io.Writer) {
+	fmt.Fprint(w, usage)
+}
+
+// Creates a flag set that reports errors instead of printing them and exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// Adds a flag named after each coin's abbreviation in the campaign's currency,
+// and returns the values they will be parsed into
+func addCoinFlags(fs *flag.FlagSet) map[string]*int {
+	coins := make(map[string]*int)
+	for _, c := range models.CurrentCurrency.Coins {
+		coins[c.Name] = fs.Int(c.Abbr, 0, c.Name+" pieces")
+	}
+	return coins
+}
+
+// Converts parsed coin flags into a coin map, rejecting negative amounts
+func coinsFromFlags(coins map[string]*int) (map[string]int, error) {
+	money := make(map[string]int)
+	for _, coinType := range models.CoinOrder {
+		if *coins[coinType] < 0 {
+			return nil, fmt.Errorf("%w: %s can't be negative", errUsage, coinType)
+		}
+		money[coinType] = *coins[coinType]
+	}
+	return money, nil
+}
+
+// Parses flags that may appear before, after or between positional arguments
+// and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// Loads the saved party and undo history. A missing save file starts a new party.
+func load() (models.Party, models.History, error) {
+	party, err := storage.LoadParty()
+	if errors.Is(err, storage.ErrCorrupt) {
+		return party, models.History{}, fmt.Errorf("loading party: %w (run \"dndgoldtracker restore\" to restore the latest backup)", err)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return party, models.History{}, fmt.Errorf("loading party: %w", err)
+	}
+
+	history, err := storage.LoadHistory()
+	if err != nil {
+		return party, history, fmt.Errorf("loading undo history: %w", err)
+	}
+	return party, history, nil
+}
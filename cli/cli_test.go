@@ -0,0 +1,560 @@
+package cli
+
+import (
+	"bytes"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Gives each test its own empty data directory so saves don't leak between tests
+func useTempData(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if err := storage.UseCampaign(storage.DefaultCampaign); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Runs a command and fails the test if it doesn't exit with the expected code
+func run(t *testing.T, expectedCode int, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if code := Run(args, &stdout, &stderr); code != expectedCode {
+		t.Fatalf("%v: expected exit code %d, got %d (stderr: %s)", args, expectedCode, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestLevelUpAnnouncements(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--xp", "2700")
+	run(t, exitOK, "member", "add", "Rowan", "--xp", "2700")
+	out := run(t, exitOK, "xp", "8000", "--members", "Keg")
+	expected := "Keg went up from Level 4 to Level 5!\n  Proficiency bonus rises to +3\n"
+	if !strings.Contains(out, expected) || strings.Contains(out, "Rowan") {
+		t.Errorf("Expected only Keg's level up to be announced, got %q", out)
+	}
+}
+
+func TestMemberAndDistributionCommands(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--xp", "900")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitOK, "coins", "--gp", "120", "--sp", "30", "--reason", "Troll hoard")
+	run(t, exitOK, "xp", "450")
+	run(t, exitOK, "member", "deactivate", "Rowan")
+	run(t, exitUsage, "member", "add", "Fred", "--share", "0.001")
+
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.ActiveMembers) != 1 || len(party.InactiveMembers) != 1 {
+		t.Fatalf("Expected 1 active and 1 inactive member, got %d and %d", len(party.ActiveMembers), len(party.InactiveMembers))
+	}
+	keg := party.ActiveMembers[0]
+	if keg.XP != 1125 || keg.Coins[models.Gold] != 60 || keg.Coins[models.Silver] != 15 {
+		t.Errorf("Unexpected state for Keg: %+v", keg)
+	}
+
+	out := run(t, exitOK, "show")
+	if !strings.Contains(out, "Rowan") || !strings.Contains(out, models.InactiveGroup) {
+		t.Errorf("Expected show to list inactive Rowan, got:\n%s", out)
+	}
+
+	ledger, err := storage.LoadLedger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger) != 5 || ledger[2].Reason != "Troll hoard" {
+		t.Errorf("Expected 5 ledger entries with the coin reason recorded, got %+v", ledger)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitUsage)
+	run(t, exitUsage, "bogus")
+	run(t, exitUsage, "xp", "lots")
+	run(t, exitUsage, "coins", "--gp", "-5")
+	run(t, exitError, "coins", "--gp", "5")
+	run(t, exitError, "member", "deactivate", "Nobody")
+}
+
+func TestShowFormats(t *testing.T) {
+	useTempData(t)
+
+	// An empty party still has a list of members
+	if out := run(t, exitOK, "show", "--format", "json"); !strings.Contains(out, `"members": []`) {
+		t.Errorf("Expected an empty members list, got %s", out)
+	}
+
+	run(t, exitOK, "member", "add", "Keg", "--xp", "1000", "--gp", "12")
+
+	var pr partyReport
+	if err := json.Unmarshal([]byte(run(t, exitOK, "show", "--format", "json")), &pr); err != nil {
+		t.Fatalf("show --format json didn't produce valid JSON: %v", err)
+	}
+	if len(pr.Members) != 1 {
+		t.Fatalf("Expected 1 member, got %d", len(pr.Members))
+	}
+	keg := pr.Members[0]
+	if keg.Level != 3 || keg.XPToNextLevel == nil || *keg.XPToNextLevel != 1700 || keg.Coins[models.Gold] != 12 {
+		t.Errorf("Unexpected member in JSON report: %+v", keg)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(run(t, exitOK, "show", "--format", "csv"))).ReadAll()
+	if err != nil {
+		t.Fatalf("show --format csv didn't produce valid CSV: %v", err)
+	}
+	if len(records) != 2 || records[1][1] != "Keg" {
+		t.Errorf("Expected a header and a row for Keg, got %v", records)
+	}
+
+	run(t, exitUsage, "show", "--format", "xml")
+}
+
+func TestCampaignCommands(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "campaign", "create", "Curse of Strahd")
+	run(t, exitUsage, "campaign", "create")
+	run(t, exitError, "campaign", "create", "Curse of Strahd")
+
+	// Saves go to the campaign being used
+	if err := storage.UseCampaign("Curse of Strahd"); err != nil {
+		t.Fatal(err)
+	}
+	run(t, exitOK, "member", "add", "Ireena")
+	run(t, exitOK, "campaign", "rename", "Curse of Strahd", "Barovia")
+	if storage.CurrentCampaign() != "Barovia" {
+		t.Errorf("Expected the current campaign to follow the rename, got %s", storage.CurrentCampaign())
+	}
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.ActiveMembers) != 1 || party.ActiveMembers[0].Name != "Ireena" {
+		t.Errorf("Expected only Ireena in the renamed campaign, got %+v", party.ActiveMembers)
+	}
+
+	// Archived campaigns are only listed with --all
+	run(t, exitOK, "campaign", "archive", storage.DefaultCampaign)
+	if out := run(t, exitOK, "campaign", "list"); strings.Contains(out, storage.DefaultCampaign) {
+		t.Errorf("Expected archived campaign to be hidden, got:\n%s", out)
+	}
+	if out := run(t, exitOK, "campaign", "list", "--all"); !strings.Contains(out, storage.DefaultCampaign) {
+		t.Errorf("Expected --all to list archived campaigns, got:\n%s", out)
+	}
+
+	run(t, exitOK, "campaign", "use", storage.DefaultCampaign)
+	if storage.LastCampaign() != storage.DefaultCampaign {
+		t.Errorf("Expected %s to be opened next time, got %s", storage.DefaultCampaign, storage.LastCampaign())
+	}
+	run(t, exitError, "campaign", "use", "Nowhere")
+}
+
+func TestMembersWithTheSameName(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitError, "member", "deactivate", "Rowan")
+
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := party.ActiveMembers[1].ID
+	run(t, exitOK, "member", "deactivate", second)
+
+	party, err = storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.InactiveMembers) != 1 || party.InactiveMembers[0].ID != second {
+		t.Errorf("Expected only the second Rowan to be inactive, got %+v", party.InactiveMembers)
+	}
+}
+
+func TestEditAndDeleteMember(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--gp", "10")
+	run(t, exitOK, "member", "add", "Rowan", "--gp", "3")
+	run(t, exitOK, "member", "edit", "Keg", "--name", "Kegan", "--xp", "2700")
+	run(t, exitUsage, "member", "edit", "Kegan", "--name", "")
+	run(t, exitOK, "member", "delete", "Rowan")
+	run(t, exitUsage, "member", "delete", "Kegan", "--wallet", "burn")
+
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.ActiveMembers) != 1 {
+		t.Fatalf("Expected 1 member left, got %+v", party.ActiveMembers)
+	}
+	kegan := party.ActiveMembers[0]
+	if kegan.Name != "Kegan" || kegan.Level != 4 || kegan.Coins[models.Gold] != 13 {
+		t.Errorf("Unexpected state after edit and delete: %+v", kegan)
+	}
+}
+
+func TestAwardToSomeMembers(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitOK, "member", "add", "Fred")
+	run(t, exitOK, "coins", "--gp", "10", "--members", "Keg, Fred")
+	run(t, exitOK, "xp", "300", "--members", "Rowan")
+	run(t, exitError, "xp", "300", "--members", "Nobody")
+
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]struct{ gold, xp int }{"Keg": {5, 0}, "Rowan": {0, 300}, "Fred": {5, 0}}
+	for _, m := range party.ActiveMembers {
+		if e := expected[m.Name]; m.Coins[models.Gold] != e.gold || m.XP != e.xp {
+			t.Errorf("%s: expected %d gold and %d XP, got %+v", m.Name, e.gold, e.xp, m)
+		}
+	}
+}
+
+func TestTreasuryCommands(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitOK, "coins", "--gp", "20", "--treasury", "50")
+	run(t, exitOK, "treasury", "deposit", "--sp", "5", "--reason", "Ship fund")
+	run(t, exitError, "treasury", "withdraw", "Keg", "--gp", "11")
+	run(t, exitOK, "treasury", "withdraw", "Keg", "--gp", "4")
+	run(t, exitUsage, "coins", "--gp", "1", "--treasury", "150")
+
+	var pr partyReport
+	if err := json.Unmarshal([]byte(run(t, exitOK, "show", "--format", "json")), &pr); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Treasury[models.Gold] != 6 || pr.Treasury[models.Silver] != 5 {
+		t.Errorf("Unexpected treasury: %v", pr.Treasury)
+	}
+	if gold := pr.Members[0].Coins[models.Gold]; gold != 9 {
+		t.Errorf("Expected Keg to have 9 gold, got %d", gold)
+	}
+}
+
+func TestSpend(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--pp", "1")
+	out := run(t, exitOK, "spend", "Keg", "--gp", "3", "--reason", "Longsword")
+	if !strings.Contains(out, "7 Gold back") {
+		t.Errorf("Expected 7 gold change, got %q", out)
+	}
+	run(t, exitError, "spend", "Keg", "--gp", "8")
+
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coins := party.ActiveMembers[0].Coins; coins[models.Platinum] != 0 || coins[models.Gold] != 7 {
+		t.Errorf("Unexpected wallet after spending: %v", coins)
+	}
+}
+
+func TestTransferAndDebts(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--gp", "10")
+	run(t, exitOK, "member", "add", "Rowan")
+	out := run(t, exitOK, "transfer", "Keg", "Rowan", "--gp", "4", "--loan")
+	if !strings.Contains(out, "Rowan owes Keg 4 Gold") {
+		t.Errorf("Expected the loan to be reported, got %q", out)
+	}
+	run(t, exitUsage, "transfer", "Keg", "Rowan", "--gp", "1", "--loan", "--repay")
+	run(t, exitError, "transfer", "Rowan", "Keg", "--gp", "5", "--repay")
+
+	out = run(t, exitOK, "debts", "--format", "csv")
+	if !strings.Contains(out, "Rowan,Keg,400,4 Gold") {
+		t.Errorf("Expected the debt to be listed, got %q", out)
+	}
+
+	// Rowan's 3 gold share of the haul goes towards the loan
+	out = run(t, exitOK, "coins", "--gp", "6", "--settle-debts")
+	if !strings.Contains(out, "Rowan paid back 3 Gold to Keg") {
+		t.Errorf("Expected a settlement, got %q", out)
+	}
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keg := party.ActiveMembers[0]; keg.Coins[models.Gold] != 12 {
+		t.Errorf("Expected Keg to have 12 gold, got %v", keg.Coins)
+	}
+	if len(party.Debts) != 1 || party.Debts[0].Amount != 100 {
+		t.Errorf("Expected Rowan to still owe 100 copper, got %+v", party.Debts)
+	}
+}
+
+func TestLootCommands(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "loot", "add", "Ruby", "--qty", "3", "--value", "500")
+	run(t, exitOK, "loot", "add", "Flame Tongue", "--value", "5000", "--weight", "3", "--notes", "Command word: Ignis")
+	run(t, exitOK, "loot", "give", "ruby", "Keg", "--qty", "2")
+	run(t, exitOK, "loot", "give", "Flame Tongue", "Keg")
+	run(t, exitOK, "loot", "attune", "Flame Tongue", "Keg")
+	run(t, exitError, "loot", "give", "Ruby", "Keg", "--qty", "2")
+	run(t, exitUsage, "loot", "pawn", "Ruby")
+
+	out := run(t, exitOK, "loot", "list", "--format", "csv")
+	for _, expected := range []string{"Loot,Ruby,1,500", "Keg,Ruby,2,500", "Keg,Flame Tongue,1,5000,3,true,Command word: Ignis"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in the inventory, got %q", expected, out)
+		}
+	}
+	if out := run(t, exitOK, "loot", "list", "--format", "json"); !strings.Contains(out, `"value": 5000`) {
+		t.Errorf("Expected the item values under a currency-neutral key, got %q", out)
+	}
+
+	run(t, exitOK, "loot", "return", "Ruby", "Keg")
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.Loot) != 1 || party.Loot[0].Quantity != 3 {
+		t.Errorf("Expected the rubies to be back together in the pool, got %+v", party.Loot)
+	}
+}
+
+func TestLootSell(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitOK, "loot", "add", "Ruby", "--qty", "2", "--value", "15")
+	run(t, exitOK, "loot", "add", "Flame Tongue", "--value", "5000")
+	run(t, exitOK, "loot", "give", "Flame Tongue", "Keg")
+
+	out := run(t, exitOK, "loot", "sell", "Ruby", "--qty", "1")
+	if !strings.Contains(out, "Sold 1 Ruby for 7 Gold, 5 Silver, split among the party") {
+		t.Errorf("Unexpected sale output: %q", out)
+	}
+	run(t, exitUsage, "loot", "sell", "Ruby", "--keep")
+	run(t, exitOK, "loot", "sell", "Flame Tongue", "--from", "Keg", "--percent", "100", "--keep", "--reason", "Duke Ulder")
+
+	out = run(t, exitOK, "loot", "sales", "--format", "csv")
+	if !strings.Contains(out, "Flame Tongue,1,5000,100,Keg,true,0,5000,0,0,0") {
+		t.Errorf("Expected the sale of the Flame Tongue in the history, got %q", out)
+	}
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keg := party.ActiveMembers[0]; keg.Coins[models.Gold] < 5000 || len(keg.Items) != 0 {
+		t.Errorf("Expected Keg to keep the 5000 gold, got %+v", keg)
+	}
+}
+
+func TestCoinsWithItemClaims(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitOK, "loot", "add", "Sapphire", "--value", "500")
+	run(t, exitUsage, "coins", "--gp", "300", "--claim", "Keg")
+	run(t, exitError, "coins", "--gp", "300", "--claim", "Keg=Ruby")
+
+	out := run(t, exitOK, "coins", "--gp", "300", "--claim", "Keg=Sapphire")
+	if !strings.Contains(out, "Keg: share 400 gp, gets 1 Sapphire worth 500 gp and 0 gp in coins, 100 gp over their share") {
+		t.Errorf("Expected the allocation to be explained, got %q", out)
+	}
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keg, rowan := party.ActiveMembers[0], party.ActiveMembers[1]; len(keg.Items) != 1 || rowan.Coins[models.Gold] != 300 {
+		t.Errorf("Expected Keg to take the sapphire and Rowan the gold, got %+v and %+v", keg, rowan)
+	}
+}
+
+func TestEncounterXP(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitUsage, "xp", "300", "--encounter", "1/4:6")
+	run(t, exitUsage, "xp", "--encounter", "1/3:2")
+	run(t, exitUsage, "xp", "300", "--apply-multiplier")
+
+	out := run(t, exitOK, "xp", "--encounter", "1/4:6")
+	if !strings.Contains(out, "Encounter: 300 XP, x2.5 for 750 XP adjusted (Deadly)") || !strings.Contains(out, "Keg: 150 XP") {
+		t.Errorf("Expected the encounter's rating and base XP, got %q", out)
+	}
+	out = run(t, exitOK, "xp", "--encounter", "0", "--apply-multiplier", "--members", "Keg")
+	if !strings.Contains(out, "(Trivial)") || !strings.Contains(out, "Keg: 165 XP") {
+		t.Errorf("Expected the multiplied XP to go to Keg, got %q", out)
+	}
+}
+
+func TestMilestoneLeveling(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg")
+	run(t, exitOK, "member", "add", "Rowan")
+	run(t, exitError, "level", "up")
+	run(t, exitUsage, "campaign", "leveling", storage.DefaultCampaign, "fast")
+	run(t, exitOK, "campaign", "leveling", storage.DefaultCampaign, "milestone")
+	if out := run(t, exitOK, "campaign", "list", "--format", "csv"); !strings.Contains(out, ",milestone") {
+		t.Errorf("Expected the campaign to list milestone leveling, got %q", out)
+	}
+
+	if out := run(t, exitOK, "xp", "1000"); strings.Contains(out, "went up") {
+		t.Errorf("Expected XP not to level anyone under milestone leveling, got %q", out)
+	}
+	out := run(t, exitOK, "level", "up", "--session", "3", "--reason", "Cleared the crypt")
+	if !strings.Contains(out, "Keg is now Level 2") || !strings.Contains(out, "Rowan is now Level 2") {
+		t.Errorf("Expected everyone to reach level 2, got %q", out)
+	}
+	run(t, exitOK, "level", "set", "Rowan", "5", "--session", "4")
+	run(t, exitUsage, "level", "set", "Rowan", "five")
+
+	out = run(t, exitOK, "level", "history", "--format", "csv")
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[1][2] != "2" || records[1][3] != "3" || records[1][4] != "Cleared the crypt" || records[3][1] != "Rowan" || records[3][2] != "5" {
+		t.Errorf("Unexpected level history %v", records)
+	}
+
+	// XP is still tracked but doesn't change levels
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keg := party.ActiveMembers[0]; keg.Level != 2 || keg.XP != 500 {
+		t.Errorf("Expected Keg at level 2 with 500 XP, got %+v", keg)
+	}
+
+	// Going back to XP leveling puts Rowan at the level their XP supports
+	out = run(t, exitOK, "campaign", "leveling", storage.DefaultCampaign, "xp")
+	if !strings.Contains(out, "Rowan is now Level 2") || strings.Contains(out, "Keg is now") {
+		t.Errorf("Expected only Rowan to drop back to level 2, got %q", out)
+	}
+	ledger, err := storage.LoadLedger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := ledger[len(ledger)-1]; last.Type != models.LevelChanged || last.Reason != "Leveling changed to xp" {
+		t.Errorf("Expected the switch in the ledger, got %+v", last)
+	}
+}
+
+func TestXPTables(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--xp", "5000")
+	out := run(t, exitOK, "xp-table", "use", "pf1-medium")
+	if !strings.Contains(out, "up to level 20") || !strings.Contains(out, "Keg is now Level 3") {
+		t.Errorf("Expected Keg to drop to level 3, got %q", out)
+	}
+	if out := run(t, exitOK, "xp-table", "list", "--format", "csv"); !strings.Contains(out, "pf1-medium,20,3600000,true") {
+		t.Errorf("Expected pf1-medium to be current, got %q", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "table.json")
+	if err := os.WriteFile(path, []byte(`{"Name": "Gritty", "Thresholds": [0, 1000, 1000]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, exitError, "xp-table", "use", path)
+	if err := os.WriteFile(path, []byte(`{"Name": "Gritty", "Thresholds": [0, 1000, 3000]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, exitOK, "xp-table", "use", path)
+	run(t, exitOK, "xp", "10000")
+	if out := run(t, exitOK, "xp-table", "show", "--format", "csv"); out != "Level,XP\n1,0\n2,1000\n3,3000\n" {
+		t.Errorf("Unexpected table %q", out)
+	}
+
+	// The table is kept with the campaign
+	if err := storage.UseCampaign(storage.DefaultCampaign); err != nil {
+		t.Fatal(err)
+	}
+	party, err := storage.LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keg := party.ActiveMembers[0]; keg.Level != 3 || models.CurrentXPTable.Name != "Gritty" {
+		t.Errorf("Expected Keg to stop at level 3 of the Gritty table, got %+v and %s", keg, models.CurrentXPTable.Name)
+	}
+}
+
+// Transactions that don't touch any member, like a table switch with nobody in the party,
+// still list their entries in JSON
+func TestLedgerWithoutEntries(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "xp-table", "use", "pf1-medium")
+	if out := run(t, exitOK, "ledger", "--format", "json"); !strings.Contains(out, `"entries": []`) {
+		t.Errorf("Expected an empty list of entries, got %q", out)
+	}
+}
+
+func TestCustomCurrency(t *testing.T) {
+	useTempData(t)
+
+	run(t, exitOK, "member", "add", "Keg", "--gp", "5")
+	run(t, exitOK, "member", "add", "Rowan")
+	realm := `{"Name": "Realm", "Standard": "Shilling", "Coins": [
+		{"Name": "Trade bar", "Abbr": "bar", "Value": 2400, "NoChange": true},
+		{"Name": "Crown", "Abbr": "cr", "Value": 60},
+		{"Name": "Shilling", "Abbr": "s", "Value": 12},
+		{"Name": "Penny", "Abbr": "d", "Value": 1}]}`
+	path := filepath.Join(t.TempDir(), "realm.json")
+	if err := os.WriteFile(path, []byte(realm), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, exitError, "currency", "use", path)
+	run(t, exitOK, "member", "edit", "Keg", "--gp", "0")
+	if err := os.WriteFile(path, []byte(strings.Replace(realm, `"d"`, `"xp"`, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, exitError, "currency", "use", path)
+	if err := os.WriteFile(path, []byte(realm), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(t, exitOK, "currency", "use", path); !strings.Contains(out, "--bar --cr --s --d") || !strings.Contains(out, "undo history was cleared") {
+		t.Errorf("Expected the new coin flags to be listed and the undo history cleared, got %q", out)
+	}
+	if history, err := storage.LoadHistory(); err != nil || len(history.Undo) != 0 {
+		t.Errorf("Expected an empty undo history, got %+v (%v)", history, err)
+	}
+
+	run(t, exitUsage, "coins", "--gp", "10")
+	run(t, exitOK, "coins", "--bar", "1", "--d", "1", "--split", "exact")
+	out := run(t, exitOK, "show", "--format", "csv")
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := records[0][8:]; strings.Join(header, ",") != "Trade bar,Crown,Shilling,Penny" {
+		t.Errorf("Expected a column for each coin of the realm, got %v", header)
+	}
+	if keg := records[1][8:]; strings.Join(keg, ",") != "0,20,0,1" {
+		t.Errorf("Expected Keg to get 20 crowns and the odd penny, got %v", keg)
+	}
+}
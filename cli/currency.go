@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Lists the built in currencies, prints the current campaign's coins or switches it to another currency
+func runCurrency(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: currency needs one of list, show or use", errUsage)
+	}
+	switch args[0] {
+	case "list", "show":
+		fs := newFlagSet("currency " + args[0])
+		format := addFormatFlag(fs)
+		positional, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) > 0 {
+			return fmt.Errorf("%w: currency %s takes no arguments, got %q", errUsage, args[0], positional[0])
+		}
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+		if args[0] == "list" {
+			return writeReport(stdout, *format, newCurrenciesReport())
+		}
+		return writeReport(stdout, *format, newCurrencyReport(models.CurrentCurrency))
+	case "use":
+		return runCurrencyUse(args[1:], stdout)
+	default:
+		return fmt.Errorf("%w: unknown currency command %q", errUsage, args[0])
+	}
+}
+
+// Switches the current campaign to a built in currency or one read from a file.
+// Refused while anyone holds coins the new currency doesn't have.
+func runCurrencyUse(args []string, stdout io.Writer) error {
+	fs := newFlagSet("currency use")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: currency use takes a built in currency name or a file", errUsage)
+	}
+	currency, ok := models.FindCurrency(positional[0])
+	if !ok {
+		if currency, err = storage.ReadCurrency(positional[0]); err != nil {
+			return err
+		}
+	}
+	for _, c := range currency.Coins {
+		if slices.Contains(reservedFlags, c.Abbr) {
+			return fmt.Errorf("the abbreviation %q for %s is already a flag name", c.Abbr, c.Name)
+		}
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	if err := commands.CheckCurrency(&party, currency); err != nil {
+		return err
+	}
+	if err := storage.SetCurrency(storage.CurrentCampaign(), currency); err != nil {
+		return err
+	}
+	// Undoing would bring back parties counted in the old coins
+	if len(history.Undo) > 0 || len(history.Redo) > 0 {
+		if err := storage.SaveHistory(&models.History{}); err != nil {
+			return fmt.Errorf("clearing undo history: %w", err)
+		}
+		fmt.Fprintln(stdout, "The undo history was cleared")
+	}
+
+	var abbrs []string
+	for _, c := range currency.Coins {
+		abbrs = append(abbrs, "--"+c.Abbr)
+	}
+	fmt.Fprintf(stdout, "Campaign %s now uses the %s currency, with coin flags %s\n", storage.CurrentCampaign(), currency.Name, strings.Join(abbrs, " "))
+	return nil
+}
+
+type currencyReport struct {
+	Name     string       `json:"name"`
+	Coins    []coinReport `json:"coins"`
+	Standard string       `json:"standard"`
+	Current  bool         `json:"current"`
+}
+
+// Builds the report printed by currency list
+func newCurrenciesReport() report {
+	reports := []currencyReport{}
+	r := report{header: []string{"Name", "Coins", "Standard", "Current"}}
+	for _, c := range models.Currencies {
+		cr := currencyReport{Name: c.Name, Coins: coinReports(c), Standard: c.Standard, Current: c.Name == models.CurrentCurrency.Name}
+		reports = append(reports, cr)
+		var abbrs []string
+		for _, coin := range c.Coins {
+			abbrs = append(abbrs, coin.Abbr)
+		}
+		r.rows = append(r.rows, []string{cr.Name, strings.Join(abbrs, " "), cr.Standard, strconv.FormatBool(cr.Current)})
+	}
+	r.data = reports
+	return r
+}
+
+type coinReport struct {
+	Name   string `json:"name"`
+	Abbr   string `json:"abbr"`
+	Value  int    `json:"value"`
+	Change bool   `json:"change"`
+}
+
+// Returns a currency's coins for reports, each valued in the smallest coin
+func coinReports(c models.Currency) []coinReport {
+	reports := []coinReport{}
+	for _, coin := range c.Coins {
+		reports = append(reports, coinReport{Name: coin.Name, Abbr: coin.Abbr, Value: coin.Value, Change: !coin.NoChange})
+	}
+	return reports
+}
+
+// Builds the report printed by currency show
+func newCurrencyReport(c models.Currency) report {
+	reports := coinReports(c)
+	r := report{data: reports, header: []string{"Coin", "Abbreviation", "Value", "Given as change"}}
+	for _, cr := range reports {
+		r.rows = append(r.rows, []string{cr.Name, cr.Abbr, strconv.Itoa(cr.Value), strconv.FormatBool(cr.Change)})
+	}
+	return r
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Levels characters directly in a campaign using milestone leveling, and lists the levels they reached
+func runLevel(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: level needs one of up, set or history", errUsage)
+	}
+	switch args[0] {
+	case "up":
+		return runLevelUp(args[1:], stdout)
+	case "set":
+		return runLevelSet(args[1:], stdout)
+	case "history":
+		return runLevelHistory(args[1:], stdout)
+	default:
+		return fmt.Errorf("%w: unknown level command %q", errUsage, args[0])
+	}
+}
+
+// Raises the active members a level for reaching a milestone
+func runLevelUp(args []string, stdout io.Writer) error {
+	fs := newFlagSet("level up")
+	session := fs.Int("session", 0, "the game session the milestone was reached in")
+	reason := fs.String("reason", "", "the milestone the party reached")
+	sharing := addMembersFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: level up takes no arguments, got %q", errUsage, positional[0])
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	ids, err := resolveParticipants(&party, *sharing)
+	if err != nil {
+		return err
+	}
+	before := party.Clone()
+	tx, err := commands.LevelUp(&party, ids, *session, *reason)
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.LevelChanged)
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	for _, e := range tx.Entries {
+		fmt.Fprintf(stdout, "%s is now Level %d\n", e.Member, e.Level)
+	}
+	return nil
+}
+
+// Puts one member at a level, e.g. a new character joining above level 1
+func runLevelSet(args []string, stdout io.Writer) error {
+	fs := newFlagSet("level set")
+	session := fs.Int("session", 0, "the game session the level was reached in")
+	reason := fs.String("reason", "", "why the level changed")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("%w: level set takes a member and a level", errUsage)
+	}
+	level, err := strconv.Atoi(positional[1])
+	if err != nil {
+		return fmt.Errorf("%w: invalid level %q", errUsage, positional[1])
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[0])
+	if err != nil {
+		return err
+	}
+	before := party.Clone()
+	tx, err := commands.SetLevel(&party, member.ID, level, *session, *reason)
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.LevelChanged)
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s is now Level %d\n", member.Name, level)
+	return nil
+}
+
+// Prints every level reached by milestone, oldest first
+func runLevelHistory(args []string, stdout io.Writer) error {
+	fs := newFlagSet("level history")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: level history takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	party, _, err := load()
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, newLevelHistoryReport(slices.Concat(party.ActiveMembers, party.InactiveMembers)))
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Adds items to the loot pool, hands them out, takes them back and lists who holds what
+func runLoot(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: loot needs one of add, give, return, attune, sell, list or sales", errUsage)
+	}
+	switch args[0] {
+	case "add":
+		return runLootAdd(args[1:], stdout)
+	case "give", "return":
+		return runLootMove(args[0], args[1:], stdout)
+	case "attune":
+		return runLootAttune(args[1:], stdout)
+	case "sell":
+		return runLootSell(args[1:], stdout)
+	case "list":
+		return runLootList(args[1:], stdout)
+	case "sales":
+		return runLootSales(args[1:], stdout)
+	default:
+		return fmt.Errorf("%w: unknown loot command %q", errUsage, args[0])
+	}
+}
+
+// Adds a newly found item to the loot pool
+func runLootAdd(args []string, stdout io.Writer) error {
+	fs := newFlagSet("loot add")
+	quantity := fs.Int("qty", 1, "how many of the item were found")
+	value := fs.Int("value", 0, "what one of the item is worth in the campaign's standard coin")
+	weight := fs.Float64("weight", 0, "what one of the item weighs in pounds")
+	notes := fs.String("notes", "", "anything worth remembering about the item")
+	reason := fs.String("reason", "", "where the item was found")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: loot add takes exactly one item name", errUsage)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	before := party.Clone()
+	tx, err := commands.AddLoot(&party, models.Item{Name: positional[0], Quantity: *quantity, ValueGP: *value, Weight: *weight, Notes: *notes})
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.LootAdded)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Added %d %s to the loot pool\n", *quantity, strings.TrimSpace(positional[0]))
+	return nil
+}
+
+// Gives an item from the loot pool to a member, or returns one from a member to the pool
+func runLootMove(action string, args []string, stdout io.Writer) error {
+	fs := newFlagSet("loot " + action)
+	quantity := fs.Int("qty", 0, "how many to move, all of them if not given")
+	reason := fs.String("reason", "", "why the item changed hands")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("%w: loot %s takes an item and a member", errUsage, action)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[1])
+	if err != nil {
+		return err
+	}
+	from := party.Loot
+	if action == "return" {
+		from = member.Items
+	}
+	item, err := resolveItem(from, positional[0])
+	if err != nil {
+		return err
+	}
+	if *quantity == 0 {
+		*quantity = item.Quantity
+	}
+
+	before := party.Clone()
+	var tx models.Transaction
+	if action == "give" {
+		tx, err = commands.AssignItem(&party, item.ID, member.ID, *quantity)
+	} else {
+		tx, err = commands.ReturnItem(&party, member.ID, item.ID, *quantity)
+	}
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, tx.Type)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	if action == "give" {
+		fmt.Fprintf(stdout, "%s took %d %s\n", member.Name, *quantity, item.Name)
+	} else {
+		fmt.Fprintf(stdout, "%s put %d %s back in the loot pool\n", member.Name, *quantity, item.Name)
+	}
+	return nil
+}
+
+// Attunes a member to one of their items, or ends the attunement
+func runLootAttune(args []string, stdout io.Writer) error {
+	fs := newFlagSet("loot attune")
+	end := fs.Bool("end", false, "end the attunement instead")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("%w: loot attune takes an item and a member", errUsage)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	member, err := resolveMember(&party, positional[1])
+	if err != nil {
+		return err
+	}
+	item, err := resolveItem(member.Items, positional[0])
+	if err != nil {
+		return err
+	}
+
+	before := party.Clone()
+	tx, err := commands.SetAttunement(&party, member.ID, item.ID, !*end)
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.ItemAttuned)
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	if *end {
+		fmt.Fprintf(stdout, "%s is no longer attuned to %s\n", member.Name, item.Name)
+	} else {
+		fmt.Fprintf(stdout, "%s is attuned to %s\n", member.Name, item.Name)
+	}
+	return nil
+}
+
+// Sells an item from the loot pool or a member's inventory
+func runLootSell(args []string, stdout io.Writer) error {
+	fs := newFlagSet("loot sell")
+	holder := fs.String("from", "", "the member selling the item, the loot pool if not given")
+	quantity := fs.Int("qty", 0, "how many to sell, all of them if not given")
+	percent := fs.Int("percent", commands.DefaultSalePercent, "how much of the listed value the merchant pays")
+	keep := fs.Bool("keep", false, "the member selling the item keeps the money instead of splitting it")
+	reason := fs.String("reason", "", "who bought the item")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: loot sell takes exactly one item", errUsage)
+	}
+	if *keep && *holder == "" {
+		return fmt.Errorf("%w: --keep needs --from to say who keeps the money", errUsage)
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	holderID, items := "", party.Loot
+	if *holder != "" {
+		member, err := resolveMember(&party, *holder)
+		if err != nil {
+			return err
+		}
+		holderID, items = member.ID, member.Items
+	}
+	item, err := resolveItem(items, positional[0])
+	if err != nil {
+		return err
+	}
+	if *quantity == 0 {
+		*quantity = item.Quantity
+	}
+
+	before := party.Clone()
+	tx, err := commands.SellItem(&party, holderID, item.ID, *quantity, commands.SaleOptions{Percent: *percent, Keep: *keep})
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, models.ItemSold)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	sale := party.Sales[len(party.Sales)-1]
+	fmt.Fprintf(stdout, "Sold %d %s for %s", sale.Quantity, sale.Item, formatCoins(sale.Coins))
+	if sale.Kept {
+		fmt.Fprintf(stdout, ", kept by %s\n", sale.Seller)
+	} else {
+		fmt.Fprintln(stdout, ", split among the party")
+	}
+	return nil
+}
+
+// Prints every item the party has sold
+func runLootSales(args []string, stdout io.Writer) error {
+	fs := newFlagSet("loot sales")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: loot sales takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	party, _, err := load()
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, newSalesReport(party.Sales))
+}
+
+// Prints the loot pool and every member's inventory
+func runLootList(args []string, stdout io.Writer) error {
+	fs := newFlagSet("loot list")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: loot list takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	party, _, err := load()
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, newInventoryReport(party))
+}
+
+// Collects the values of a repeated --claim flag
+type claimList []string
+
+func (c *claimList) String() string {
+	return strings.Join(*c, ", ")
+}
+
+func (c *claimList) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// Resolves NAME=ITEM[:QTY] claims to members and loot pool items. Without a quantity the whole stack is claimed.
+func resolveClaims(party *models.Party, claims claimList) ([]commands.ItemClaim, error) {
+	var resolved []commands.ItemClaim
+	for _, claim := range claims {
+		memberName, itemName, ok := strings.Cut(claim, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: --claim %q should look like NAME=ITEM or NAME=ITEM:QTY", errUsage, claim)
+		}
+		quantity := 0
+		if i := strings.LastIndex(itemName, ":"); i >= 0 {
+			n, err := strconv.Atoi(itemName[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: --claim %q has a quantity that isn't a number", errUsage, claim)
+			}
+			itemName, quantity = itemName[:i], n
+		}
+		member, err := resolveMember(party, memberName)
+		if err != nil {
+			return nil, err
+		}
+		item, err := resolveItem(party.Loot, itemName)
+		if err != nil {
+			return nil, err
+		}
+		if quantity == 0 {
+			quantity = item.Quantity
+		}
+		resolved = append(resolved, commands.ItemClaim{MemberID: member.ID, ItemID: item.ID, Quantity: quantity})
+	}
+	return resolved, nil
+}
+
+// Explains how a member's items and coins add up to their share of a haul
+func describeAllocation(share commands.MemberShare) string {
+	s := share.Name + ": share " + models.CurrentCurrency.Format(share.FairValue) + ", gets "
+	if len(share.Items) > 0 {
+		var items []string
+		for _, item := range share.Items {
+			items = append(items, fmt.Sprintf("%d %s", item.Quantity, item.Name))
+		}
+		s += strings.Join(items, ", ") + " worth " + models.CurrentCurrency.Format(share.ItemValue) + " and "
+	}
+	s += models.CurrentCurrency.Format(commands.CoinValue(share.Coins)) + " in coins"
+	if over := share.ItemValue - share.FairValue; over > 0 {
+		s += ", " + models.CurrentCurrency.Format(over) + " over their share"
+	}
+	return s
+}
+
+// Finds an item by ID, or by name if only one stack has that name
+func resolveItem(items []models.Item, nameOrID string) (models.Item, error) {
+	var found []models.Item
+	for _, item := range items {
+		if item.ID == nameOrID {
+			return item, nil
+		}
+		if strings.EqualFold(item.Name, nameOrID) {
+			found = append(found, item)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return models.Item{}, fmt.Errorf("no item named %q", nameOrID)
+	case 1:
+		return found[0], nil
+	default:
+		var ids []string
+		for _, item := range found {
+			ids = append(ids, item.ID)
+		}
+		return models.Item{}, fmt.Errorf("more than one item is named %q, use one of their IDs instead: %s", nameOrID, strings.Join(ids, ", "))
+	}
+}
@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats for show and reports
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+var formats = []string{formatTable, formatJSON, formatCSV}
+
+// A report can be written as JSON or as rows for tables and CSV
+type report struct {
+	data   any
+	header []string
+	rows   [][]string
+}
+
+// Adds the --format flag to a command
+func addFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", formatTable, "output format: "+strings.Join(formats, ", "))
+}
+
+// Checks the value given to --format
+func checkFormat(format string) error {
+	if !slices.Contains(formats, format) {
+		return fmt.Errorf("%w: unknown format %q, expected one of %s", errUsage, format, strings.Join(formats, ", "))
+	}
+	return nil
+}
+
+// Writes a report in the requested format
+func writeReport(w io.Writer, format string, r report) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.data)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(r.header)
+		cw.WriteAll(r.rows)
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(r.header, "\t"))
+		for _, row := range r.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+type memberReport struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Group         string         `json:"group"`
+	Level         int            `json:"level"`
+	XP            int            `json:"xp"`
+	XPToNextLevel *int           `json:"xp_to_next_level"` // null at the maximum level
+	CoinPriority  int            `json:"coin_priority"`
+	Share         float64        `json:"share"`
+	Coins         map[string]int `json:"coins"`
+}
+
+type partyReport struct {
+	Members  []memberReport `json:"members"`
+	Treasury map[string]int `json:"treasury"`
+}
+
+// Builds the report printed by show
+func newPartyReport(party models.Party) report {
+	pr := partyReport{Members: []memberReport{}, Treasury: make(map[string]int)}
+	for _, coinType := range models.CoinOrder {
+		pr.Treasury[coinType] = party.Treasury[coinType]
+	}
+	addMembers := func(members []models.Member, group string) {
+		for _, m := range members {
+			mr := memberReport{
+				ID:           m.ID,
+				Name:         m.Name,
+				Group:        group,
+				Level:        m.Level,
+				XP:           m.XP,
+				CoinPriority: m.CoinPriority,
+				Share:        m.Share,
+				Coins:        make(map[string]int),
+			}
+			if next, ok := commands.XPToNextLevel(m); ok {
+				mr.XPToNextLevel = &next
+			}
+			for _, coinType := range models.CoinOrder {
+				mr.Coins[coinType] = m.Coins[coinType]
+			}
+			pr.Members = append(pr.Members, mr)
+		}
+	}
+	addMembers(party.ActiveMembers, models.ActiveGroup)
+	addMembers(party.InactiveMembers, models.InactiveGroup)
+
+	r := report{
+		data:   pr,
+		header: slices.Concat([]string{"ID", "Name", "Group", "Level", "XP", "XP to next level", "Coin priority", "Share"}, models.CoinOrder),
+	}
+	for _, mr := range pr.Members {
+		next := ""
+		if mr.XPToNextLevel != nil {
+			next = strconv.Itoa(*mr.XPToNextLevel)
+		}
+		row := []string{mr.ID, mr.Name, mr.Group, strconv.Itoa(mr.Level), strconv.Itoa(mr.XP), next, strconv.Itoa(mr.CoinPriority), strconv.FormatFloat(mr.Share, 'f', -1, 64)}
+		for _, coinType := range models.CoinOrder {
+			row = append(row, strconv.Itoa(mr.Coins[coinType]))
+		}
+		r.rows = append(r.rows, row)
+	}
+
+	// Once the treasury holds anything it gets a row of its own with only coins filled in
+	if slices.ContainsFunc(models.CoinOrder, func(c string) bool { return pr.Treasury[c] != 0 }) {
+		row := []string{"", models.TreasuryName, "", "", "", "", "", ""}
+		for _, coinType := range models.CoinOrder {
+			row = append(row, strconv.Itoa(pr.Treasury[coinType]))
+		}
+		r.rows = append(r.rows, row)
+	}
+	return r
+}
+
+type itemReport struct {
+	ID       string  `json:"id"`
+	Holder   string  `json:"holder"`
+	HolderID string  `json:"holder_id,omitempty"`
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Value    int     `json:"value"`
+	Weight   float64 `json:"weight"`
+	Attuned  bool    `json:"attuned"`
+	Notes    string  `json:"notes,omitempty"`
+}
+
+// Builds the report printed by loot list, starting with the loot pool
+func newInventoryReport(party models.Party) report {
+	items := []itemReport{}
+	r := report{header: []string{"ID", "Holder", "Item", "Quantity", valueHeader(), "Weight (lb)", "Attuned", "Notes"}}
+	addItems := func(holderID string, holder string, inventory []models.Item) {
+		for _, item := range inventory {
+			ir := itemReport{
+				ID:       item.ID,
+				Holder:   holder,
+				HolderID: holderID,
+				Name:     item.Name,
+				Quantity: item.Quantity,
+				Value:    item.ValueGP,
+				Weight:   item.Weight,
+				Attuned:  item.Attuned,
+				Notes:    item.Notes,
+			}
+			items = append(items, ir)
+			r.rows = append(r.rows, []string{ir.ID, ir.Holder, ir.Name, strconv.Itoa(ir.Quantity), strconv.Itoa(ir.Value),
+				strconv.FormatFloat(ir.Weight, 'f', -1, 64), strconv.FormatBool(ir.Attuned), ir.Notes})
+		}
+	}
+	addItems("", models.LootName, party.Loot)
+	for _, m := range slices.Concat(party.ActiveMembers, party.InactiveMembers) {
+		addItems(m.ID, m.Name, m.Items)
+	}
+	r.data = items
+	return r
+}
+
+// Returns the header for item values. Like the value field of the JSON reports they're
+// given in the currency's standard coin
+func valueHeader() string {
+	return "Value (" + models.CurrentCurrency.StandardCoin().Abbr + ")"
+}
+
+type saleReport struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Item      string         `json:"item"`
+	Quantity  int            `json:"quantity"`
+	Value     int            `json:"value"`
+	Percent   int            `json:"percent"`
+	Coins     map[string]int `json:"coins"`
+	SellerID  string         `json:"seller_id,omitempty"`
+	Seller    string         `json:"seller"`
+	Kept      bool           `json:"kept"`
+}
+
+// Builds the report printed by loot sales
+func newSalesReport(sales []models.Sale) report {
+	reports := []saleReport{}
+	r := report{header: slices.Concat([]string{"Time", "Item", "Quantity", valueHeader(), "Percent", "Seller", "Kept"}, models.CoinOrder)}
+	for _, s := range sales {
+		sr := saleReport{
+			Timestamp: s.Timestamp,
+			Item:      s.Item,
+			Quantity:  s.Quantity,
+			Value:     s.ValueGP,
+			Percent:   s.Percent,
+			Coins:     s.Coins,
+			SellerID:  s.SellerID,
+			Seller:    s.Seller,
+			Kept:      s.Kept,
+		}
+		reports = append(reports, sr)
+		row := []string{s.Timestamp.Format(time.DateTime), s.Item, strconv.Itoa(s.Quantity), strconv.Itoa(s.ValueGP),
+			strconv.Itoa(s.Percent), s.Seller, strconv.FormatBool(s.Kept)}
+		for _, coinType := range models.CoinOrder {
+			row = append(row, strconv.Itoa(s.Coins[coinType]))
+		}
+		r.rows = append(r.rows, row)
+	}
+	r.data = reports
+	return r
+}
+
+type milestoneReport struct {
+	Timestamp time.Time `json:"timestamp"`
+	MemberID  string    `json:"member_id"`
+	Member    string    `json:"member"`
+	Level     int       `json:"level"`
+	Session   int       `json:"session,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Builds the report printed by level history, with every member's milestones in the order they happened
+func newLevelHistoryReport(members []models.Member) report {
+	reports := []milestoneReport{}
+	for _, m := range members {
+		for _, milestone := range m.Milestones {
+			reports = append(reports, milestoneReport{
+				Timestamp: milestone.Timestamp,
+				MemberID:  m.ID,
+				Member:    m.Name,
+				Level:     milestone.Level,
+				Session:   milestone.Session,
+				Reason:    milestone.Reason,
+			})
+		}
+	}
+	slices.SortStableFunc(reports, func(a, b milestoneReport) int { return a.Timestamp.Compare(b.Timestamp) })
+
+	r := report{data: reports, header: []string{"Time", "Member", "Level", "Session", "Reason"}}
+	for _, mr := range reports {
+		session := ""
+		if mr.Session > 0 {
+			session = strconv.Itoa(mr.Session)
+		}
+		r.rows = append(r.rows, []string{mr.Timestamp.Format(time.DateTime), mr.Member, strconv.Itoa(mr.Level), session, mr.Reason})
+	}
+	return r
+}
+
+type debtReport struct {
+	BorrowerID string `json:"borrower_id"`
+	Borrower   string `json:"borrower"`
+	LenderID   string `json:"lender_id"`
+	Lender     string `json:"lender"`
+	BaseUnits  int    `json:"base_units"` // what's owed in the currency's smallest coin
+}
+
+// Builds the report printed by debts
+func newDebtReport(party models.Party) report {
+	debts := []debtReport{}
+	r := report{header: []string{"Borrower", "Lender", models.CurrentCurrency.Base().Name, "Owed"}}
+	for _, d := range party.Debts {
+		dr := debtReport{
+			BorrowerID: d.BorrowerID,
+			Borrower:   memberName(party, d.BorrowerID),
+			LenderID:   d.LenderID,
+			Lender:     memberName(party, d.LenderID),
+			BaseUnits:  d.Amount,
+		}
+		debts = append(debts, dr)
+		r.rows = append(r.rows, []string{dr.Borrower, dr.Lender, strconv.Itoa(dr.BaseUnits), formatCoins(commands.CoinsWorth(dr.BaseUnits))})
+	}
+	r.data = debts
+	return r
+}
+
+type ledgerEntryReport struct {
+	MemberID string         `json:"member_id,omitempty"`
+	Member   string         `json:"member"`
+	Coins    map[string]int `json:"coins,omitempty"`
+	XP       int            `json:"xp,omitempty"`
+	Group    string         `json:"group,omitempty"`
+	Item     string         `json:"item,omitempty"`
+	Quantity int            `json:"quantity,omitempty"`
+	Level    int            `json:"level,omitempty"`
+}
+
+type transactionReport struct {
+	Type      string              `json:"type"`
+	Timestamp time.Time           `json:"timestamp"`
+	Reason    string              `json:"reason,omitempty"`
+	Entries   []ledgerEntryReport `json:"entries"`
+}
+
+// Builds the ledger report, with one table row per member affected by each transaction
+func newLedgerReport(ledger []models.Transaction) report {
+	transactions := []transactionReport{}
+	r := report{header: slices.Concat([]string{"Time", "Type", "Reason", "Member", "XP", "Group", "Item", "Quantity", "Level"}, models.CoinOrder)}
+	for _, tx := range ledger {
+		tr := transactionReport{Type: tx.Type, Timestamp: tx.Timestamp, Reason: tx.Reason, Entries: []ledgerEntryReport{}}
+		for _, e := range tx.Entries {
+			tr.Entries = append(tr.Entries, ledgerEntryReport{MemberID: e.MemberID, Member: e.Member, Coins: e.Coins, XP: e.XP, Group: e.Group, Item: e.Item, Quantity: e.Quantity, Level: e.Level})
+
+			row := []string{tx.Timestamp.Format(time.DateTime), tx.Type, tx.Reason, e.Member, strconv.Itoa(e.XP), e.Group, e.Item, strconv.Itoa(e.Quantity), strconv.Itoa(e.Level)}
+			for _, coinType := range models.CoinOrder {
+				row = append(row, strconv.Itoa(e.Coins[coinType]))
+			}
+			r.rows = append(r.rows, row)
+		}
+		if len(tx.Entries) == 0 {
+			r.rows = append(r.rows, slices.Concat(
+				[]string{tx.Timestamp.Format(time.DateTime), tx.Type, tx.Reason, "", "", "", "", "", ""},
+				make([]string, len(models.CoinOrder))))
+		}
+		transactions = append(transactions, tr)
+	}
+	r.data = transactions
+	return r
+}
+
+// Lists coins for printing, e.g. "2 Gold, 15 Silver"
+func formatCoins(money map[string]int) string {
+	var parts []string
+	for _, coinType := range models.CoinOrder {
+		if amount := money[coinType]; amount != 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", amount, coinType))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+)
+
+// Moves coins from one member to another, as a gift, a loan or a repayment
+func runTransfer(args []string, stdout io.Writer) error {
+	fs := newFlagSet("transfer")
+	coins := addCoinFlags(fs)
+	loan := fs.Bool("loan", false, "the recipient owes the coins back")
+	repay := fs.Bool("repay", false, "the coins pay back what the sender owes the recipient")
+	reason := fs.String("reason", "", "what the coins are for")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("%w: transfer takes the member giving the coins and the member receiving them", errUsage)
+	}
+	if *loan && *repay {
+		return fmt.Errorf("%w: --loan and --repay can't be used together", errUsage)
+	}
+	money, err := coinsFromFlags(coins)
+	if err != nil {
+		return err
+	}
+	kind := commands.GiftTransfer
+	switch {
+	case *loan:
+		kind = commands.LoanTransfer
+	case *repay:
+		kind = commands.RepaymentTransfer
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	from, err := resolveMember(&party, positional[0])
+	if err != nil {
+		return err
+	}
+	to, err := resolveMember(&party, positional[1])
+	if err != nil {
+		return err
+	}
+
+	before := party.Clone()
+	tx, err := commands.TransferCoins(&party, from.ID, to.ID, money, kind)
+	if err != nil {
+		return err
+	}
+	commands.Record(&history, &before, tx.Type)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s gave %s %s\n", from.Name, to.Name, formatCoins(money))
+	if owed := commands.Owed(&party, from.ID, to.ID); owed > 0 {
+		fmt.Fprintf(stdout, "%s owes %s %s\n", to.Name, from.Name, formatCoins(commands.CoinsWorth(owed)))
+	} else if owed := commands.Owed(&party, to.ID, from.ID); owed > 0 {
+		fmt.Fprintf(stdout, "%s owes %s %s\n", from.Name, to.Name, formatCoins(commands.CoinsWorth(owed)))
+	}
+	return nil
+}
+
+// Prints what members owe each other
+func runDebts(args []string, stdout io.Writer) error {
+	fs := newFlagSet("debts")
+	format := addFormatFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: debts takes no arguments, got %q", errUsage, positional[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	party, _, err := load()
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, newDebtReport(party))
+}
+
+// Looks up a member's name for reports, whichever group they're in
+func memberName(party models.Party, id string) string {
+	for _, m := range party.ActiveMembers {
+		if m.ID == id {
+			return m.Name
+		}
+	}
+	for _, m := range party.InactiveMembers {
+		if m.ID == id {
+			return m.Name
+		}
+	}
+	return id
+}
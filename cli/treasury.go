@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+)
+
+// Deposits coins into the party treasury or withdraws them to a member
+func runTreasury(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: treasury needs one of deposit or withdraw", errUsage)
+	}
+	if args[0] != "deposit" && args[0] != "withdraw" {
+		return fmt.Errorf("%w: unknown treasury command %q", errUsage, args[0])
+	}
+
+	fs := newFlagSet("treasury " + args[0])
+	coins := addCoinFlags(fs)
+	reason := fs.String("reason", "", "what the coins are for")
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	expected := 0
+	if args[0] == "withdraw" {
+		expected = 1
+	}
+	if len(positional) != expected {
+		return fmt.Errorf("%w: treasury %s takes %d member name(s) or ID(s)", errUsage, args[0], expected)
+	}
+	money, err := coinsFromFlags(coins)
+	if err != nil {
+		return err
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+
+	before := party.Clone()
+	var tx models.Transaction
+	if args[0] == "deposit" {
+		tx = commands.Deposit(&party, money)
+	} else {
+		member, err := resolveMember(&party, positional[0])
+		if err != nil {
+			return err
+		}
+		if tx, err = commands.Withdraw(&party, member.ID, money); err != nil {
+			return err
+		}
+	}
+	commands.Record(&history, &before, tx.Type)
+	tx.Reason = *reason
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "The treasury holds %s\n", formatCoins(party.Treasury))
+	return nil
+}
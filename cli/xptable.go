@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Lists the built in XP tables, prints the current campaign's table or switches it to another one
+func runXPTable(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: xp-table needs one of list, show or use", errUsage)
+	}
+	switch args[0] {
+	case "list", "show":
+		fs := newFlagSet("xp-table " + args[0])
+		format := addFormatFlag(fs)
+		positional, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) > 0 {
+			return fmt.Errorf("%w: xp-table %s takes no arguments, got %q", errUsage, args[0], positional[0])
+		}
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+		if args[0] == "list" {
+			return writeReport(stdout, *format, newXPTablesReport())
+		}
+		return writeReport(stdout, *format, newXPTableReport(models.CurrentXPTable))
+	case "use":
+		return runXPTableUse(args[1:], stdout)
+	default:
+		return fmt.Errorf("%w: unknown xp-table command %q", errUsage, args[0])
+	}
+}
+
+// Switches the current campaign to a built in XP table or one read from a file,
+// working out everyone's level again
+func runXPTableUse(args []string, stdout io.Writer) error {
+	fs := newFlagSet("xp-table use")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: xp-table use takes a built in table name or a file", errUsage)
+	}
+	table, ok := models.FindXPTable(positional[0])
+	if !ok {
+		if table, err = storage.ReadXPTable(positional[0]); err != nil {
+			return err
+		}
+	}
+
+	party, history, err := load()
+	if err != nil {
+		return err
+	}
+	if err := storage.SetXPTable(storage.CurrentCampaign(), table); err != nil {
+		return err
+	}
+	// The table is a campaign setting rather than part of the party, so the switch can't be undone
+	tx := commands.UpdateLevels(&party)
+	if err := storage.Commit(&party, &history, tx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Campaign %s now uses the %s XP table, up to level %d\n", storage.CurrentCampaign(), table.Name, table.MaxLevel())
+	for _, e := range tx.Entries {
+		fmt.Fprintf(stdout, "%s is now Level %d\n", e.Member, e.Level)
+	}
+	return nil
+}
+
+type xpTableReport struct {
+	Name       string `json:"name"`
+	Levels     int    `json:"levels"`
+	MaxXP      int    `json:"max_xp"`
+	Current    bool   `json:"current"`
+	Thresholds []int  `json:"thresholds"`
+}
+
+// Builds the report printed by xp-table list
+func newXPTablesReport() report {
+	reports := []xpTableReport{}
+	r := report{header: []string{"Name", "Levels", "XP for the highest level", "Current"}}
+	for _, t := range models.XPTables {
+		tr := xpTableReport{
+			Name:       t.Name,
+			Levels:     t.MaxLevel(),
+			MaxXP:      t.Thresholds[t.MaxLevel()-1],
+			Current:    t.Name == models.CurrentXPTable.Name,
+			Thresholds: t.Thresholds,
+		}
+		reports = append(reports, tr)
+		r.rows = append(r.rows, []string{tr.Name, strconv.Itoa(tr.Levels), strconv.Itoa(tr.MaxXP), strconv.FormatBool(tr.Current)})
+	}
+	r.data = reports
+	return r
+}
+
+type levelThresholdReport struct {
+	Level int `json:"level"`
+	XP    int `json:"xp"`
+}
+
+// Builds the report printed by xp-table show
+func newXPTableReport(table models.XPTable) report {
+	reports := []levelThresholdReport{}
+	r := report{header: []string{"Level", "XP"}}
+	for i, xp := range table.Thresholds {
+		reports = append(reports, levelThresholdReport{Level: i + 1, XP: xp})
+		r.rows = append(r.rows, []string{strconv.Itoa(i + 1), strconv.Itoa(xp)})
+	}
+	r.data = reports
+	return r
+}
@@ -2,111 +2,204 @@ package commands
 
 import (
 	"dndgoldtracker/models"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"slices"
-	"sort"
 )
 
+// ErrMemberNotFound is returned when a member ID doesn't match anyone in the party
+var ErrMemberNotFound = errors.New("member not found")
+
 // Adds a new member to the active member list and gives them last Coin Priority
-func AddMember(p *models.Party, name string, xp int, money map[string]int) {
-	m := models.Member{Name: name, Level: determineLevel(xp), XP: xp, Coins: money, CoinPriority: len(p.ActiveMembers)}
+// Gives them a new unique ID
+func AddMember(p *models.Party, name string, xp int, money map[string]int) models.Transaction {
+	m := models.Member{ID: models.NewID(), Name: name, Level: determineLevel(xp), XP: xp, Coins: money, CoinPriority: len(p.ActiveMembers), Share: models.FullShare}
 	p.ActiveMembers = append(p.ActiveMembers, m)
 	log.Printf("Welcome to the party %s!\n", m.Name)
+
+	tx := models.NewTransaction(models.MemberAdded, "")
+	tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: m.ID, Member: m.Name, Coins: maps.Clone(money), XP: xp, Group: models.ActiveGroup})
+	return tx
 }
 
-// Moves a member to a different group e.g. Active to Inactive
-func ChangeMemberGroup(srcGroup *[]models.Member, dstGroup *[]models.Member, index int) {
+// Moves the member with the given ID to a different group e.g. Active to Inactive
+func ChangeMemberGroup(p *models.Party, id string, dstName string) (models.Transaction, error) {
+	srcGroup, dstGroup := &p.InactiveMembers, &p.ActiveMembers
+	if dstName == models.InactiveGroup {
+		srcGroup, dstGroup = dstGroup, srcGroup
+	}
+	index := FindMember(*srcGroup, id)
+	if index < 0 {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s to move to %s", ErrMemberNotFound, id, dstName)
+	}
+
 	*dstGroup = append(*dstGroup, (*srcGroup)[index])
 	(*dstGroup)[len(*dstGroup)-1].CoinPriority = len(*dstGroup) - 1
 	*srcGroup = slices.Delete((*srcGroup), index, index+1)
+
+	moved := (*dstGroup)[len(*dstGroup)-1]
+	tx := models.NewTransaction(models.GroupChange, "")
+	tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: moved.ID, Member: moved.Name, Group: dstName})
+	return tx, nil
 }
 
-// DistributeCoins distributes coins fairly among party members in a fixed order
-// Hands extras out one at a time and rotates coin priority
-func DistributeCoins(p *models.Party, money map[string]int) {
-	numMembers := len(p.ActiveMembers)
-	if numMembers == 0 {
-		log.Println("No members to distribute coins to.")
-		return
-	}
+// FindMember returns the index of the member with the given ID, or -1 if there isn't one
+func FindMember(members []models.Member, id string) int {
+	return slices.IndexFunc(members, func(m models.Member) bool { return m.ID == id })
+}
 
-	// Initialize coin maps if not already set
-	for i := range p.ActiveMembers {
-		if p.ActiveMembers[i].Coins == nil {
-			p.ActiveMembers[i].Coins = make(map[string]int)
+// FindMembersByName returns every active and inactive member with the given name
+func FindMembersByName(p *models.Party, name string) []models.Member {
+	var found []models.Member
+	for _, m := range slices.Concat(p.ActiveMembers, p.InactiveMembers) {
+		if m.Name == name {
+			found = append(found, m)
 		}
 	}
+	return found
+}
 
-	// Helper function to distribute a specific coin type
-	distributeCoin := func(coinType string, coinAmount int) {
-		each := coinAmount / numMembers
-		remainder := coinAmount % numMembers
-
-		// Assign evenly to each member
-		for i := range p.ActiveMembers {
-			log.Printf("Adding %d of %s to %s's wallet\n", each, coinType, p.ActiveMembers[i].Name)
-			p.ActiveMembers[i].Coins[coinType] += each
-		}
-
-		// Sort members by priority for distributing the remainder
-		sort.Slice(p.ActiveMembers, func(i, j int) bool {
-			return p.ActiveMembers[i].CoinPriority < p.ActiveMembers[j].CoinPriority
-		})
+// DistributeCoins distributes coins fairly among party members in a fixed order
+// Hands extras out one at a time and rotates coin priority
+func DistributeCoins(p *models.Party, money map[string]int) models.Transaction {
+	return ApplyCoinPlan(p, PlanCoinDistribution(p, money, CoinOptions{}))
+}
 
-		// Distribute excess coins based on priority
-		for i := range remainder {
-			p.ActiveMembers[i].Coins[coinType]++
-		}
+// LevelUpEvent is a member going up one or more levels after an XP award
+type LevelUpEvent struct {
+	MemberID string
+	Member   string
+	From     int
+	To       int
+}
 
-		// Rotate priority to balance future distributions
-		for i := range p.ActiveMembers {
-			p.ActiveMembers[i].CoinPriority = (p.ActiveMembers[i].CoinPriority + 1) % numMembers
+// Changes lists what the member gains on the way to their new level, if the campaign follows 5e:
+// a higher proficiency bonus and any Ability Score Improvements
+func (e LevelUpEvent) Changes() []string {
+	if !models.CurrentXPTable.FifthEdition() {
+		return nil
+	}
+	var changes []string
+	if bonus := models.ProficiencyBonus(e.To); bonus > models.ProficiencyBonus(e.From) {
+		changes = append(changes, fmt.Sprintf("Proficiency bonus rises to +%d", bonus))
+	}
+	for _, level := range models.ASILevels {
+		if level > e.From && level <= e.To {
+			changes = append(changes, fmt.Sprintf("Ability Score Improvement at level %d", level))
 		}
 	}
+	return changes
+}
 
-	// Distribute coins in the predefined order
-	for _, coinType := range models.CoinOrder {
-		amount, exists := money[coinType]
-		if exists {
-			distributeCoin(coinType, amount)
+// DistributeExperience distributes XP by share weight and checks for level-ups,
+// returning one event for each member who went up a level.
+// Everyone's XP is rounded down, so XP that doesn't split evenly isn't handed out.
+func DistributeExperience(p *models.Party, xp int) (models.Transaction, []LevelUpEvent) {
+	return DistributeExperienceTo(p, xp, nil)
+}
+
+// DistributeExperienceTo distributes XP like DistributeExperience, but only among the
+// active members with the given IDs. Nil IDs means every active member.
+func DistributeExperienceTo(p *models.Party, xp int, ids []string) (models.Transaction, []LevelUpEvent) {
+	tx := models.NewTransaction(models.XPAward, "")
+	var levelUps []LevelUpEvent
+	var indexes []int
+	for i, m := range p.ActiveMembers {
+		if ids == nil || slices.Contains(ids, m.ID) {
+			indexes = append(indexes, i)
 		}
 	}
-}
+	weights := make([]int, len(indexes))
+	order := make([]int, len(indexes))
+	for j, i := range indexes {
+		weights[j] = weightUnits(p.ActiveMembers[i].Share)
+		order[j] = j
+	}
 
-// DistributeExperience distributes XP and checks for level-ups
-func DistributeExperience(p *models.Party, xp int) {
-	for i := range p.ActiveMembers {
-		p.ActiveMembers[i].XP += xp / len(p.ActiveMembers)
-		checkLevelUp(&p.ActiveMembers[i])
+	shares, extras := splitByWeight(xp, weights, order)
+	for j, i := range indexes {
+		// Handing out the leftover XP would favour the same members every time
+		share := shares[j] - extras[j]
+		p.ActiveMembers[i].XP += share
+		if event, ok := checkLevelUp(&p.ActiveMembers[i]); ok {
+			levelUps = append(levelUps, event)
+		}
+		tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: p.ActiveMembers[i].ID, Member: p.ActiveMembers[i].Name, XP: share})
 	}
 
 	log.Println("XP added!")
+	return tx, levelUps
 }
 
 func GetFirstCoinPriority(p *models.Party) int {
 	return slices.IndexFunc(p.ActiveMembers, func(m models.Member) bool { return m.CoinPriority == 0 })
 }
 
-func checkLevelUp(member *models.Member) {
+// XPToNextLevel returns how much more XP a member needs to reach their next level.
+// Returns false if the member is already at the maximum level, or levels by milestone.
+func XPToNextLevel(member models.Member) (int, bool) {
+	if models.UsesMilestones() || member.Level < 1 || member.Level >= MaxLevel() {
+		return 0, false
+	}
+	return max(models.CurrentXPTable.Thresholds[member.Level]-member.XP, 0), true
+}
 
-	for member.Level < len(models.XpThresholds)-1 {
-		if member.XP >= models.XpThresholds[member.Level] {
-			member.Level++
-			log.Printf("🎉 %s leveled up to Level %d! 🎉\n", member.Name, member.Level)
-		} else {
-			break
-		}
+// Raises a member's level to match their XP, unless the campaign levels by milestone.
+// Returns false if the member didn't go up a level.
+func checkLevelUp(member *models.Member) (LevelUpEvent, bool) {
+	if models.UsesMilestones() {
+		return LevelUpEvent{}, false
 	}
+	event := LevelUpEvent{MemberID: member.ID, Member: member.Name, From: member.Level}
+	table := models.CurrentXPTable
+	for member.Level < table.MaxLevel() && member.XP >= table.Thresholds[member.Level] {
+		member.Level++
+		log.Printf("🎉 %s leveled up to Level %d! 🎉\n", member.Name, member.Level)
+	}
+	event.To = member.Level
+	return event, event.To > event.From
 }
 
-// Determines the level of a character for a given amount of xp
+// Determines the level of a character for a given amount of xp.
+// Under milestone leveling new characters start at level 1.
 func determineLevel(xp int) int {
-	for i := range models.XpThresholds {
-		if xp < models.XpThresholds[i] {
-			return i
-		}
+	if models.UsesMilestones() {
+		return 1
 	}
+	return models.CurrentXPTable.Level(xp)
+}
+
+// MaxLevel returns the highest level a character can reach in the campaign's XP table
+func MaxLevel() int {
+	return models.CurrentXPTable.MaxLevel()
+}
 
-	// max level
-	return 20
+// UpdateLevels works every member's level out again after the campaign's XP table changed.
+// Under milestone leveling levels are kept, only dropping to the table's highest level.
+func UpdateLevels(p *models.Party) models.Transaction {
+	tx := models.NewTransaction(models.LevelChanged, "XP table changed to "+models.CurrentXPTable.Name)
+	tx.Entries = matchLevels(p)
+	return tx
+}
+
+// Puts every member at the level the campaign's XP table and leveling allow,
+// returning an entry for each member whose level changed
+func matchLevels(p *models.Party) []models.LedgerEntry {
+	var entries []models.LedgerEntry
+	for _, group := range [][]models.Member{p.ActiveMembers, p.InactiveMembers} {
+		for i := range group {
+			member := &group[i]
+			level := min(member.Level, MaxLevel())
+			if !models.UsesMilestones() {
+				level = determineLevel(member.XP)
+			}
+			if level != member.Level {
+				member.Level = level
+				entries = append(entries, models.LedgerEntry{MemberID: member.ID, Member: member.Name, Level: level})
+			}
+		}
+	}
+	return entries
 }
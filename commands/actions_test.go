@@ -2,6 +2,7 @@ package commands
 
 import (
 	"dndgoldtracker/models"
+	"slices"
 	"testing"
 )
 
@@ -9,8 +10,8 @@ import (
 func TestDistributeExperience(t *testing.T) {
 	party := models.Party{
 		ActiveMembers: []models.Member{
-			{Name: "Keg", Level: 1, XP: 0},
-			{Name: "Rowan", Level: 1, XP: 0},
+			{ID: "keg", Name: "Keg", Level: 1, XP: 0},
+			{ID: "rowan", Name: "Rowan", Level: 1, XP: 0},
 		},
 	}
 
@@ -31,9 +32,9 @@ func TestDistributeCoins(t *testing.T) {
 	// Create a mock party with 3 members
 	party := models.Party{
 		ActiveMembers: []models.Member{
-			{Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
-			{Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
-			{Name: "Fred", CoinPriority: 2, Coins: make(map[string]int)},
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+			{ID: "fred", Name: "Fred", CoinPriority: 2, Coins: make(map[string]int)},
 		},
 	}
 
@@ -96,3 +97,310 @@ func getMemberByName(members []models.Member, name string) *models.Member {
 	}
 	return nil
 }
+
+func TestDistributeCoinsTransaction(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+		},
+	}
+
+	tx := DistributeCoins(&party, map[string]int{models.Gold: 7, models.Silver: 4})
+
+	if tx.Type != models.CoinAward {
+		t.Errorf("Expected transaction type %s, got %s", models.CoinAward, tx.Type)
+	}
+	if len(tx.Entries) != 2 {
+		t.Fatalf("Expected 2 ledger entries, got %d", len(tx.Entries))
+	}
+
+	// Every coin handed out must show up in the ledger
+	total := make(map[string]int)
+	for _, entry := range tx.Entries {
+		member := getMemberByName(party.ActiveMembers, entry.Member)
+		for coin, amount := range entry.Coins {
+			total[coin] += amount
+			if member.Coins[coin] != amount {
+				t.Errorf("%s's %s: ledger says %d, wallet has %d", entry.Member, coin, amount, member.Coins[coin])
+			}
+		}
+	}
+	if total[models.Gold] != 7 || total[models.Silver] != 4 {
+		t.Errorf("Expected ledger totals of 7 gold and 4 silver, got %v", total)
+	}
+}
+
+func TestPlanCoinDistribution(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 1, Coins: map[string]int{models.Gold: 2}},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "fred", Name: "Fred", CoinPriority: 2, Coins: make(map[string]int)},
+		},
+	}
+
+	plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 8}, CoinOptions{})
+
+	// Planning must not touch the party
+	if party.ActiveMembers[0].Coins[models.Gold] != 2 || party.ActiveMembers[1].CoinPriority != 0 {
+		t.Fatal("Planning a distribution changed the party")
+	}
+
+	// Rowan and Keg are first in line for the 2 leftover gold
+	expected := map[string]int{"Keg": 1, "Rowan": 1, "Fred": 0}
+	for _, share := range plan.Shares {
+		if share.Extra[models.Gold] != expected[share.Name] {
+			t.Errorf("%s's extra gold: expected %d, got %d", share.Name, expected[share.Name], share.Extra[models.Gold])
+		}
+		if share.Coins[models.Gold] != 2+expected[share.Name] {
+			t.Errorf("%s's gold: expected %d, got %d", share.Name, 2+expected[share.Name], share.Coins[models.Gold])
+		}
+	}
+
+	ApplyCoinPlan(&party, plan)
+	if gold := getMemberByName(party.ActiveMembers, "Keg").Coins[models.Gold]; gold != 5 {
+		t.Errorf("Keg's gold after applying: expected 5, got %d", gold)
+	}
+	if priority := getMemberByName(party.ActiveMembers, "Fred").CoinPriority; priority != 0 {
+		t.Errorf("Fred's priority after applying: expected 0, got %d", priority)
+	}
+}
+
+func TestPlanCoinDistributionByValue(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 1},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 0},
+			{ID: "fred", Name: "Fred", CoinPriority: 2},
+		},
+	}
+
+	// 1250 copper worth, which leaves 2 copper over after 416 each
+	money := map[string]int{models.Platinum: 1, models.Gold: 2, models.Silver: 5}
+	plan := PlanCoinDistribution(&party, money, CoinOptions{Strategy: SplitByValue})
+
+	expected := map[string]int{"Rowan": 417, "Keg": 417, "Fred": 416}
+	priorities := map[string]int{"Rowan": 1, "Keg": 2, "Fred": 0}
+	for _, share := range plan.Shares {
+		value := 0
+		for coinType, amount := range share.Coins {
+			value += amount * models.CoinValues[coinType]
+		}
+		if value != expected[share.Name] {
+			t.Errorf("%s's share: expected %d copper worth, got %d (%v)", share.Name, expected[share.Name], value, share.Coins)
+		}
+		if share.CoinPriority != priorities[share.Name] {
+			t.Errorf("%s's priority: expected %d, got %d", share.Name, priorities[share.Name], share.CoinPriority)
+		}
+	}
+	if len(plan.Exchanges) == 0 || plan.Exchanges[0].From != models.Platinum {
+		t.Errorf("Expected the platinum to be broken into change, got %+v", plan.Exchanges)
+	}
+}
+
+func TestPlanCoinDistributionExact(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 1},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 0},
+			{ID: "fred", Name: "Fred", CoinPriority: 2},
+		},
+	}
+
+	// The leftover gold becomes 10 silver, and the leftover silver 10 copper
+	plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 7}, CoinOptions{Strategy: SplitExact})
+
+	for _, share := range plan.Shares {
+		copper := 3
+		if share.Name == "Rowan" {
+			copper = 4
+		}
+		if share.Coins[models.Gold] != 2 || share.Coins[models.Silver] != 3 || share.Coins[models.Copper] != copper {
+			t.Errorf("%s's share: expected 2 gold, 3 silver and %d copper, got %v", share.Name, copper, share.Coins)
+		}
+	}
+	expected := []CoinExchange{{From: models.Gold, To: models.Silver, Count: 1}, {From: models.Silver, To: models.Copper, Count: 1}}
+	if !slices.Equal(plan.Exchanges, expected) {
+		t.Errorf("Expected exchanges %v, got %v", expected, plan.Exchanges)
+	}
+}
+
+func TestWeightedShares(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Share: 1},
+			{ID: "hireling", Name: "Hireling", CoinPriority: 1, Share: 0.5},
+			{ID: "ship", Name: "Ship fund", CoinPriority: 2, Share: 2},
+		},
+	}
+
+	// 100 gold over 3.5 shares is 28.57 gold a share. Keg loses the most to
+	// rounding, ahead of the hireling's 14.29 and the ship fund's 57.14.
+	plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 100}, CoinOptions{})
+	expected := map[string]int{"Keg": 29, "Hireling": 14, "Ship fund": 57}
+	for _, share := range plan.Shares {
+		if share.Coins[models.Gold] != expected[share.Name] {
+			t.Errorf("%s's gold: expected %d, got %d", share.Name, expected[share.Name], share.Coins[models.Gold])
+		}
+	}
+
+	DistributeExperience(&party, 700)
+	expected = map[string]int{"Keg": 200, "Hireling": 100, "Ship fund": 400}
+	for _, member := range party.ActiveMembers {
+		if member.XP != expected[member.Name] {
+			t.Errorf("%s's XP: expected %d, got %d", member.Name, expected[member.Name], member.XP)
+		}
+	}
+}
+
+func TestDistributeToParticipants(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+			{ID: "fred", Name: "Fred", CoinPriority: 2, Coins: make(map[string]int)},
+		},
+	}
+	sharing := []string{"keg", "fred"}
+
+	plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 3}, CoinOptions{Participants: sharing})
+	if len(plan.Shares) != 2 {
+		t.Fatalf("Expected shares for Keg and Fred only, got %+v", plan.Shares)
+	}
+	ApplyCoinPlan(&party, plan)
+	DistributeExperienceTo(&party, 100, sharing)
+
+	// Rowan sat out, so keeps their priority while Keg and Fred swap theirs
+	expected := map[string]struct{ gold, xp, priority int }{
+		"Keg":   {2, 50, 2},
+		"Rowan": {0, 0, 1},
+		"Fred":  {1, 50, 0},
+	}
+	for _, m := range party.ActiveMembers {
+		e := expected[m.Name]
+		if m.Coins[models.Gold] != e.gold || m.XP != e.xp || m.CoinPriority != e.priority {
+			t.Errorf("%s: expected %d gold, %d XP and priority %d, got %+v", m.Name, e.gold, e.xp, e.priority, m)
+		}
+	}
+}
+
+func TestLevelsFollowXPTable(t *testing.T) {
+	party := models.Party{ActiveMembers: []models.Member{{ID: "keg", Name: "Keg", Level: 1, Share: 1}}}
+	DistributeExperience(&party, 400000)
+	if keg := party.ActiveMembers[0]; keg.Level != 20 {
+		t.Errorf("Expected 400000 XP to reach level 20, got %d", keg.Level)
+	}
+	if _, ok := XPToNextLevel(party.ActiveMembers[0]); ok {
+		t.Error("Expected no next level at level 20")
+	}
+
+	homebrew, _ := models.FindXPTable("homebrew-30")
+	models.CurrentXPTable = homebrew
+	t.Cleanup(func() { models.CurrentXPTable = models.XPTables[0] })
+
+	tx := UpdateLevels(&party)
+	if keg := party.ActiveMembers[0]; keg.Level != 20 || len(tx.Entries) != 0 {
+		t.Errorf("Expected Keg to stay at level 20, got %+v", keg)
+	}
+	if next, ok := XPToNextLevel(party.ActiveMembers[0]); !ok || next != 5000 {
+		t.Errorf("Expected 5000 XP to level 21, got %d", next)
+	}
+	DistributeExperience(&party, 700000)
+	if keg := party.ActiveMembers[0]; keg.Level != 30 {
+		t.Errorf("Expected 1100000 XP to reach level 30, got %d", keg.Level)
+	}
+
+	models.CurrentXPTable = models.XPTable{Name: "Gritty", Thresholds: []int{0, 1000, 3000}}
+	tx = UpdateLevels(&party)
+	if keg := party.ActiveMembers[0]; keg.Level != 3 || len(tx.Entries) != 1 || tx.Entries[0].Level != 3 {
+		t.Errorf("Expected Keg to drop to level 3, got %+v and %+v", keg, tx)
+	}
+}
+
+func TestXPTableValidation(t *testing.T) {
+	for _, table := range models.XPTables {
+		if err := table.Validate(); err != nil {
+			t.Errorf("Built in table: %v", err)
+		}
+	}
+	for _, table := range []models.XPTable{
+		{Name: "Empty"},
+		{Thresholds: []int{0, 100}},
+		{Name: "Late start", Thresholds: []int{100, 200}},
+		{Name: "Flat", Thresholds: []int{0, 100, 100}},
+		{Name: "Backwards", Thresholds: []int{0, 200, 100}},
+	} {
+		if err := table.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", table)
+		}
+	}
+}
+
+func TestLevelUpEvents(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Level: 3, XP: 2700, Share: 1},
+			{ID: "rowan", Name: "Rowan", Level: 1, XP: 0, Share: 1},
+		},
+	}
+	_, levelUps := DistributeExperience(&party, 7600)
+	expected := []LevelUpEvent{
+		{MemberID: "keg", Member: "Keg", From: 3, To: 5},
+		{MemberID: "rowan", Member: "Rowan", From: 1, To: 4},
+	}
+	if !slices.Equal(levelUps, expected) {
+		t.Fatalf("Expected level-ups %+v, got %+v", expected, levelUps)
+	}
+	if changes := levelUps[0].Changes(); !slices.Equal(changes, []string{"Proficiency bonus rises to +3", "Ability Score Improvement at level 4"}) {
+		t.Errorf("Unexpected changes for Keg: %q", changes)
+	}
+	if changes := levelUps[1].Changes(); !slices.Equal(changes, []string{"Ability Score Improvement at level 4"}) {
+		t.Errorf("Unexpected changes for Rowan: %q", changes)
+	}
+
+	if _, levelUps = DistributeExperience(&party, 100); len(levelUps) != 0 {
+		t.Errorf("Expected nobody to level from 50 XP each, got %+v", levelUps)
+	}
+
+	pathfinder, _ := models.FindXPTable("pf1-fast")
+	models.CurrentXPTable = pathfinder
+	t.Cleanup(func() { models.CurrentXPTable = models.XPTables[0] })
+	if changes := (LevelUpEvent{From: 3, To: 5}).Changes(); changes != nil {
+		t.Errorf("Expected no 5e changes under a Pathfinder table, got %q", changes)
+	}
+}
+
+func TestTinySharesStillSplit(t *testing.T) {
+	// Shares this small can't be entered any more but may be in older saves
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Share: 0.001, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Share: 0.001, Coins: make(map[string]int)},
+		},
+	}
+	DistributeCoins(&party, map[string]int{models.Gold: 10})
+	for _, m := range party.ActiveMembers {
+		if m.Coins[models.Gold] != 5 {
+			t.Errorf("Expected %s to get 5 gold, got %d", m.Name, m.Coins[models.Gold])
+		}
+	}
+}
+
+func TestExperienceRemainderIsDropped(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Level: 1, Share: 1},
+			{ID: "rowan", Name: "Rowan", Level: 1, Share: 1},
+			{ID: "fred", Name: "Fred", Level: 1, Share: 1},
+		},
+	}
+	for range 3 {
+		DistributeExperience(&party, 100)
+	}
+	for _, m := range party.ActiveMembers {
+		if m.XP != 99 {
+			t.Errorf("Expected %s to have 99 XP, got %d", m.Name, m.XP)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ItemClaim is a member taking an item from the loot pool as part of their share of a haul
+type ItemClaim struct {
+	MemberID string
+	ItemID   string
+	Quantity int
+}
+
+// PlanAllocation works out a distribution where some members take items from the loot pool
+// as part of their share. Item values count against each member's share of the coins and items
+// together, and the coins are split by value so everyone's total comes out as equal as possible.
+// A member whose items are worth more than their share gets no coins, and the rest is split among
+// everyone else. Nothing changes until the plan is applied.
+func PlanAllocation(p *models.Party, money map[string]int, opts CoinOptions, claims []ItemClaim) (CoinPlan, error) {
+	plan := newPlan(p, money, opts)
+	if len(plan.Shares) == 0 {
+		return plan, errors.New("there are no active members to share the haul")
+	}
+	plan.Strategy = SplitByValue
+
+	claimed := make(map[string]int)
+	for _, c := range claims {
+		i := slices.IndexFunc(plan.Shares, func(s MemberShare) bool { return s.ID == c.MemberID })
+		if i < 0 {
+			return plan, fmt.Errorf("%w: no member sharing the haul has ID %s", ErrMemberNotFound, c.MemberID)
+		}
+		j := slices.IndexFunc(p.Loot, func(item models.Item) bool { return item.ID == c.ItemID })
+		if j < 0 {
+			return plan, fmt.Errorf("%w: no item in the loot pool has ID %s", ErrItemNotFound, c.ItemID)
+		}
+		item := p.Loot[j]
+		claimed[item.ID] += c.Quantity
+		if c.Quantity < 1 || claimed[item.ID] > item.Quantity {
+			return plan, fmt.Errorf("only %d %s can be claimed", item.Quantity, item.Name)
+		}
+		item.Quantity = c.Quantity
+		plan.Shares[i].Items = append(plan.Shares[i].Items, item)
+		plan.Shares[i].ItemValue += item.Value() * models.CurrentCurrency.StandardCoin().Value
+	}
+
+	planWithItems(&plan)
+	if opts.SettleDebts {
+		planSettlements(&plan, p)
+	}
+	return plan, nil
+}
+
+// Splits the coins so that each member's coins and items add up to their share of the whole haul.
+// Copper left over goes out one piece at a time in priority order, as with a split by value.
+func planWithItems(plan *CoinPlan) {
+	pool := plan.splitMoney()
+	coins := CoinValue(pool)
+	weights := shareWeights(plan.Shares)
+	order := priorityOrder(plan.Shares)
+
+	total := coins
+	for _, share := range plan.Shares {
+		total += share.ItemValue
+	}
+	fair, _ := splitByWeight(total, weights, order)
+	for i := range plan.Shares {
+		plan.Shares[i].FairValue = fair[i]
+	}
+
+	// Anyone whose items are worth more than their share drops out of the split,
+	// which lowers everyone else's share, until the coins cover the rest
+	sharing := slices.Clone(weights)
+	var cuts, extras []int
+	for {
+		total = coins
+		for i, share := range plan.Shares {
+			if sharing[i] > 0 {
+				total += share.ItemValue
+			}
+		}
+		cuts, extras = splitByWeight(total, sharing, order)
+		dropped := false
+		for i, share := range plan.Shares {
+			if sharing[i] > 0 && cuts[i] < share.ItemValue {
+				sharing[i] = 0
+				dropped = true
+			}
+		}
+		if !dropped {
+			break
+		}
+	}
+
+	owed := make([]int, len(plan.Shares))
+	for _, i := range order {
+		if sharing[i] == 0 {
+			continue
+		}
+		owed[i] = cuts[i] - plan.Shares[i].ItemValue
+		extras[i] = min(extras[i], owed[i])
+		takeValue(plan, pool, &plan.Shares[i], owed[i]-extras[i])
+	}
+	handedOut := 0
+	for _, i := range order {
+		if extras[i] > 0 {
+			takeValue(plan, pool, &plan.Shares[i], extras[i])
+			plan.Shares[i].Extra[models.CurrentCurrency.Base().Name] += extras[i]
+			handedOut += extras[i]
+		}
+	}
+	shiftPriority(plan, -handedOut)
+}
+
+// ClaimsItems reports whether anyone takes items as part of the plan
+func (plan CoinPlan) ClaimsItems() bool {
+	return slices.ContainsFunc(plan.Shares, func(s MemberShare) bool { return len(s.Items) > 0 })
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"testing"
+)
+
+func TestPlanAllocation(t *testing.T) {
+	newParty := func(gemValue int) models.Party {
+		return models.Party{
+			ActiveMembers: []models.Member{
+				{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+				{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+				{ID: "fred", Name: "Fred", CoinPriority: 2, Coins: make(map[string]int)},
+			},
+			Loot: []models.Item{{ID: "gem", Name: "Sapphire", Quantity: 1, ValueGP: gemValue}},
+		}
+	}
+	claims := []ItemClaim{{MemberID: "keg", ItemID: "gem", Quantity: 1}}
+
+	t.Run("items count against the share", func(t *testing.T) {
+		party := newParty(300)
+		plan, err := PlanAllocation(&party, map[string]int{models.Gold: 600}, CoinOptions{}, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []int{0, 300, 300}
+		for i, share := range plan.Shares {
+			if share.Coins[models.Gold] != expected[i] || share.FairValue != 30000 {
+				t.Errorf("Expected %s to get %d gold of a 300 gold share, got %+v", share.Name, expected[i], share)
+			}
+		}
+
+		ApplyCoinPlan(&party, plan)
+		if len(party.Loot) != 0 || len(party.ActiveMembers[0].Items) != 1 || party.ActiveMembers[1].Coins[models.Gold] != 300 {
+			t.Errorf("Expected Keg to take the sapphire and the others the gold, got %+v", party)
+		}
+	})
+
+	t.Run("items worth more than the share", func(t *testing.T) {
+		party := newParty(900)
+		plan, err := PlanAllocation(&party, map[string]int{models.Gold: 300}, CoinOptions{}, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.Shares[0].FairValue != 40000 || CoinValue(plan.Shares[0].Coins) != 0 {
+			t.Errorf("Expected Keg to get no coins towards a 400 gold share, got %+v", plan.Shares[0])
+		}
+		if plan.Shares[1].Coins[models.Gold] != 150 || plan.Shares[2].Coins[models.Gold] != 150 {
+			t.Errorf("Expected the gold to be split between Rowan and Fred, got %+v", plan.Shares)
+		}
+	})
+
+	t.Run("invalid claims", func(t *testing.T) {
+		party := newParty(300)
+		invalid := [][]ItemClaim{
+			{{MemberID: "nobody", ItemID: "gem", Quantity: 1}},
+			{{MemberID: "keg", ItemID: "missing", Quantity: 1}},
+			{{MemberID: "keg", ItemID: "gem", Quantity: 2}},
+			{{MemberID: "keg", ItemID: "gem", Quantity: 1}, {MemberID: "rowan", ItemID: "gem", Quantity: 1}},
+		}
+		for _, c := range invalid {
+			if _, err := PlanAllocation(&party, map[string]int{models.Gold: 10}, CoinOptions{}, c); err == nil {
+				t.Errorf("Expected %+v to be rejected", c)
+			}
+		}
+	})
+}
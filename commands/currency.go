@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"fmt"
+	"slices"
+)
+
+// CheckCurrency returns an error if switching the campaign from the current currency to c
+// would change what the party's money is worth. Every coin held has to keep its value, debts
+// are counted in the smallest coin and item values and sales in the standard coin, so those
+// can't change while there are any. The undo history is in the old coins too, so it should be
+// cleared by the switch.
+func CheckCurrency(p *models.Party, c models.Currency) error {
+	from, to := models.CurrentCurrency, c
+	held := func(holder string, coins map[string]int) error {
+		for coinType, amount := range coins {
+			if amount == 0 {
+				continue
+			}
+			coin, ok := findCoin(to, coinType)
+			if !ok {
+				return fmt.Errorf("%s holds %d %s, which the %s currency doesn't have", holder, amount, coinType, to.Name)
+			}
+			if old, ok := findCoin(from, coinType); ok && old.Value != coin.Value {
+				return fmt.Errorf("%s holds %d %s, which the %s currency values at %d %s instead of %d %s",
+					holder, amount, coinType, to.Name, coin.Value, to.Base().Abbr, old.Value, from.Base().Abbr)
+			}
+		}
+		return nil
+	}
+	for _, member := range slices.Concat(p.ActiveMembers, p.InactiveMembers) {
+		if err := held(member.Name, member.Coins); err != nil {
+			return err
+		}
+	}
+	if err := held(models.TreasuryName, p.Treasury); err != nil {
+		return err
+	}
+
+	if len(p.Debts) > 0 && from.Base() != to.Base() {
+		return fmt.Errorf("debts are counted in %s, which isn't the smallest coin of the %s currency, pay them off first", from.Base().Name, to.Name)
+	}
+	hasItems := len(p.Loot) > 0 || len(p.Sales) > 0
+	for _, member := range slices.Concat(p.ActiveMembers, p.InactiveMembers) {
+		hasItems = hasItems || len(member.Items) > 0
+	}
+	if standard := from.StandardCoin(); hasItems && !sameWorth(standard, to) {
+		return fmt.Errorf("item values and sales are given in %s, which isn't the standard coin of the %s currency at the same value", standard.Name, to.Name)
+	}
+	return nil
+}
+
+// Reports whether a coin is the standard coin of a currency and worth the same in it
+func sameWorth(coin models.Coin, c models.Currency) bool {
+	standard := c.StandardCoin()
+	return standard.Name == coin.Name && standard.Value == coin.Value
+}
+
+// Returns the coin of a currency with the given name
+func findCoin(c models.Currency, name string) (models.Coin, bool) {
+	i := slices.IndexFunc(c.Coins, func(coin models.Coin) bool { return coin.Name == name })
+	if i < 0 {
+		return models.Coin{}, false
+	}
+	return c.Coins[i], true
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"maps"
+	"testing"
+)
+
+// A homebrew currency with a trade bar nobody gives change in
+var realm = models.Currency{
+	Name: "Realm",
+	Coins: []models.Coin{
+		{Name: "Trade bar", Abbr: "bar", Value: 2400, NoChange: true},
+		{Name: "Crown", Abbr: "cr", Value: 60},
+		{Name: "Shilling", Abbr: "s", Value: 12},
+		{Name: "Penny", Abbr: "d", Value: 1},
+	},
+	Standard: "Shilling",
+}
+
+// Switches the package to the realm currency for the rest of a test
+func useRealm(t *testing.T) {
+	t.Helper()
+	models.UseCurrency(realm)
+	t.Cleanup(func() { models.UseCurrency(models.Currencies[0]) })
+}
+
+func TestCustomCurrency(t *testing.T) {
+	if err := realm.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	useRealm(t)
+
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+		},
+	}
+	plan := PlanCoinDistribution(&party, map[string]int{"Trade bar": 1, "Penny": 1}, CoinOptions{Strategy: SplitExact})
+	for _, share := range plan.Shares {
+		if share.Coins["Crown"] != 20 || share.Coins["Trade bar"] != 0 {
+			t.Errorf("Expected the trade bar to be broken into 20 crowns each, got %+v", share)
+		}
+	}
+	if plan.Shares[0].Extra["Penny"] != 1 {
+		t.Errorf("Expected the odd penny to go to Keg, got %+v", plan.Shares[0])
+	}
+
+	if coins := CoinsWorth(75); !maps.Equal(coins, map[string]int{"Crown": 1, "Shilling": 1, "Penny": 3}) {
+		t.Errorf("Expected 75 pennies as a crown, a shilling and 3 pennies, got %v", coins)
+	}
+	// Merchants pay in shillings and pennies
+	if price := SalePrice(models.Item{ValueGP: 10}, 1, 50); !maps.Equal(price, map[string]int{"Shilling": 5}) {
+		t.Errorf("Expected half of 10 shillings, got %v", price)
+	}
+	if s := models.CurrentCurrency.Format(18); s != "1.5 s" {
+		t.Errorf("Expected 18 pennies to read 1.5 s, got %s", s)
+	}
+}
+
+func TestCurrencyValidation(t *testing.T) {
+	for _, c := range models.Currencies {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Built in currency: %v", err)
+		}
+	}
+	broken := func(change func(c *models.Currency)) models.Currency {
+		c := realm
+		c.Coins = append([]models.Coin(nil), realm.Coins...)
+		change(&c)
+		return c
+	}
+	for name, c := range map[string]models.Currency{
+		"no name":          broken(func(c *models.Currency) { c.Name = "" }),
+		"no coins":         broken(func(c *models.Currency) { c.Coins = nil }),
+		"duplicate abbr":   broken(func(c *models.Currency) { c.Coins[1].Abbr = "s" }),
+		"bad abbr":         broken(func(c *models.Currency) { c.Coins[1].Abbr = "Cr" }),
+		"out of order":     broken(func(c *models.Currency) { c.Coins[1].Value = 6000 }),
+		"base not 1":       broken(func(c *models.Currency) { c.Coins[3].Value = 2 }),
+		"uneven change":    broken(func(c *models.Currency) { c.Coins[1].Value = 50 }),
+		"unknown standard": broken(func(c *models.Currency) { c.Standard = "Mark" }),
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected %+v to be rejected", name, c)
+		}
+	}
+}
+
+func TestCheckCurrency(t *testing.T) {
+	party := models.Party{ActiveMembers: []models.Member{{Name: "Keg", Coins: map[string]int{models.Gold: 0}}}}
+	if err := CheckCurrency(&party, realm); err != nil {
+		t.Errorf("Expected empty wallets to switch currency, got %v", err)
+	}
+	party.Treasury = map[string]int{models.Gold: 3}
+	if err := CheckCurrency(&party, realm); err == nil {
+		t.Error("Expected gold in the treasury to stop the switch")
+	}
+	gsc, _ := models.FindCurrency("gsc")
+	if err := CheckCurrency(&party, gsc); err != nil {
+		t.Errorf("Expected gold to keep its value in gsc, got %v", err)
+	}
+	cheapGold := gsc
+	cheapGold.Coins = []models.Coin{{Name: models.Gold, Abbr: "gp", Value: 20}, {Name: models.Copper, Abbr: "cp", Value: 1}}
+	if err := CheckCurrency(&party, cheapGold); err == nil {
+		t.Error("Expected gold changing value to stop the switch")
+	}
+
+	party.Treasury = nil
+	party.Debts = []models.Debt{{LenderID: "keg", BorrowerID: "rowan", Amount: 50}}
+	if err := CheckCurrency(&party, realm); err == nil {
+		t.Error("Expected a debt in copper to stop the switch")
+	}
+	if err := CheckCurrency(&party, gsc); err != nil {
+		t.Errorf("Expected a debt in copper to allow gsc, got %v", err)
+	}
+	party.Debts = nil
+
+	party.Loot = []models.Item{{Name: "Ruby", Quantity: 1, ValueGP: 50}}
+	if err := CheckCurrency(&party, realm); err == nil {
+		t.Error("Expected loot valued in gold to stop the switch")
+	}
+	if err := CheckCurrency(&party, gsc); err != nil {
+		t.Errorf("Expected loot valued in gold to allow gsc, got %v", err)
+	}
+}
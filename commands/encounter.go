@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MonsterGroup is a number of monsters of the same challenge rating
+type MonsterGroup struct {
+	CR    string
+	Count int
+}
+
+// Encounter is the XP and difficulty of a fight against some monsters
+type Encounter struct {
+	Monsters   []MonsterGroup
+	BaseXP     int     // the XP the monsters are worth
+	Multiplier float64 // the DMG multiplier for the number of monsters and the party's size
+	AdjustedXP int     // BaseXP times Multiplier, which the difficulty is judged on
+	Thresholds []int   // the party's XP threshold for each of models.Difficulties
+	Difficulty string  // the hardest difficulty reached, or "Trivial" if it's below Easy
+}
+
+// The difficulty of an encounter that doesn't reach the party's Easy threshold
+const TrivialDifficulty = "Trivial"
+
+// ParseMonsters reads a list like "1/4:6, 2:1", giving each challenge rating and how many
+// monsters had it. A challenge rating without a count is a single monster.
+func ParseMonsters(s string) ([]MonsterGroup, error) {
+	var groups []MonsterGroup
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		cr, countText, hasCount := strings.Cut(part, ":")
+		cr = strings.TrimSpace(cr)
+		if _, ok := models.CRExperience[cr]; !ok {
+			return nil, fmt.Errorf("unknown challenge rating %q", cr)
+		}
+		count := 1
+		if hasCount {
+			var err error
+			if count, err = strconv.Atoi(strings.TrimSpace(countText)); err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid number of CR %s monsters %q", cr, countText)
+			}
+		}
+		groups = append(groups, MonsterGroup{CR: cr, Count: count})
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("an encounter needs at least one monster")
+	}
+	return groups, nil
+}
+
+// PlanEncounter works out the XP for an encounter and how hard it is for the active members
+// with the given IDs, or every active member if ids is nil
+func PlanEncounter(p *models.Party, monsters []MonsterGroup, ids []string) Encounter {
+	e := Encounter{Monsters: monsters, Thresholds: make([]int, len(models.Difficulties)), Difficulty: TrivialDifficulty}
+	count := 0
+	for _, g := range monsters {
+		e.BaseXP += models.CRExperience[g.CR] * g.Count
+		count += g.Count
+	}
+
+	members := participants(p, ids)
+	for _, member := range members {
+		level := min(max(member.Level, 1), len(models.DifficultyThresholds))
+		for i, xp := range models.DifficultyThresholds[level-1] {
+			e.Thresholds[i] += xp
+		}
+	}
+
+	e.Multiplier = encounterMultiplier(count, len(members))
+	e.AdjustedXP = int(math.Round(float64(e.BaseXP) * e.Multiplier))
+	if len(members) > 0 {
+		for i, threshold := range e.Thresholds {
+			if e.AdjustedXP >= threshold {
+				e.Difficulty = models.Difficulties[i]
+			}
+		}
+	}
+	return e
+}
+
+// Award returns the XP the party earns for the encounter. The DMG only uses the multiplier to judge
+// difficulty, but some tables award the adjusted XP instead.
+func (e Encounter) Award(withMultiplier bool) int {
+	if withMultiplier {
+		return e.AdjustedXP
+	}
+	return e.BaseXP
+}
+
+// Returns the DMG encounter multiplier for a number of monsters. Parties of fewer than three
+// use the next multiplier up, and parties of six or more the next one down.
+func encounterMultiplier(monsters int, partySize int) float64 {
+	var i int
+	switch {
+	case monsters <= 1:
+		i = 1
+	case monsters == 2:
+		i = 2
+	case monsters <= 6:
+		i = 3
+	case monsters <= 10:
+		i = 4
+	case monsters <= 14:
+		i = 5
+	default:
+		i = 6
+	}
+	switch {
+	case partySize < 3:
+		i++
+	case partySize >= 6:
+		i--
+	}
+	return models.EncounterMultipliers[i]
+}
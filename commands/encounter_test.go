@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"slices"
+	"testing"
+)
+
+func TestParseMonsters(t *testing.T) {
+	monsters, err := ParseMonsters(" 1/4:6, 2 ,5: 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []MonsterGroup{{"1/4", 6}, {"2", 1}, {"5", 1}}
+	if !slices.Equal(monsters, expected) {
+		t.Errorf("Expected %v, got %v", expected, monsters)
+	}
+	for _, bad := range []string{"", " , ", "31", "1/3:2", "2:0", "2:x"} {
+		if _, err := ParseMonsters(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestPlanEncounter(t *testing.T) {
+	party := models.Party{}
+	for i, level := range []int{3, 3, 3, 4} {
+		party.ActiveMembers = append(party.ActiveMembers, models.Member{ID: string(rune('a' + i)), Level: level})
+	}
+	tests := []struct {
+		name       string
+		monsters   []MonsterGroup
+		ids        []string
+		multiplier float64
+		adjusted   int
+		difficulty string
+	}{
+		{"lone ogre", []MonsterGroup{{"2", 1}}, nil, 1, 450, "Easy"},
+		{"goblin pack", []MonsterGroup{{"1/4", 6}, {"1", 1}}, nil, 2.5, 1250, "Hard"},
+		{"rats", []MonsterGroup{{"0", 2}}, nil, 1.5, 30, TrivialDifficulty},
+		{"two against an ogre", []MonsterGroup{{"2", 1}}, []string{"a", "b"}, 1.5, 675, "Hard"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := PlanEncounter(&party, tt.monsters, tt.ids)
+			if e.Multiplier != tt.multiplier || e.AdjustedXP != tt.adjusted || e.Difficulty != tt.difficulty {
+				t.Errorf("Expected x%g for %d XP (%s), got %+v", tt.multiplier, tt.adjusted, tt.difficulty, e)
+			}
+			if e.Award(false) != e.BaseXP || e.Award(true) != e.AdjustedXP {
+				t.Errorf("Unexpected award for %+v", e)
+			}
+		})
+	}
+}
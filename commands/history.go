@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"cmp"
+	"dndgoldtracker/models"
+	"log"
+	"maps"
+	"slices"
+)
+
+// The number of changes that can be undone
+const maxHistory = 50
+
+// Record saves the current party state so the action about to be applied can be undone.
+// Recording a new action clears anything that could have been redone.
+func Record(h *models.History, p *models.Party, action string) {
+	h.Undo = append(h.Undo, models.Snapshot{Action: action, Party: p.Clone()})
+	if len(h.Undo) > maxHistory {
+		h.Undo = h.Undo[len(h.Undo)-maxHistory:]
+	}
+	h.Redo = nil
+}
+
+// Undo restores the party to the state before the most recent action.
+// Switching XP tables isn't recorded, so levels are put back in step with the current table.
+// Returns false if there is nothing to undo.
+func Undo(h *models.History, p *models.Party) (models.Transaction, bool) {
+	if len(h.Undo) == 0 {
+		return models.Transaction{}, false
+	}
+	s := h.Undo[len(h.Undo)-1]
+	h.Undo = h.Undo[:len(h.Undo)-1]
+	before := p.Clone()
+	h.Redo = append(h.Redo, models.Snapshot{Action: s.Action, Party: before})
+	*p = s.Party
+	matchLevels(p)
+
+	log.Printf("Undid %s\n", s.Action)
+	tx := models.NewTransaction(models.Undo, s.Action)
+	tx.Entries = changesBetween(before, *p)
+	return tx, true
+}
+
+// Redo reapplies the most recently undone action, with levels in step with the current XP table.
+// Returns false if there is nothing to redo.
+func Redo(h *models.History, p *models.Party) (models.Transaction, bool) {
+	if len(h.Redo) == 0 {
+		return models.Transaction{}, false
+	}
+	s := h.Redo[len(h.Redo)-1]
+	h.Redo = h.Redo[:len(h.Redo)-1]
+	before := p.Clone()
+	h.Undo = append(h.Undo, models.Snapshot{Action: s.Action, Party: before})
+	*p = s.Party
+	matchLevels(p)
+
+	log.Printf("Redid %s\n", s.Action)
+	tx := models.NewTransaction(models.Redo, s.Action)
+	tx.Entries = changesBetween(before, *p)
+	return tx, true
+}
+
+// Returns the ledger entries that take the party from one state to another, so the ledger
+// still adds up after an undo or redo: the change in each member's coins and XP, any level
+// that changed, and the change in the treasury
+func changesBetween(from models.Party, to models.Party) []models.LedgerEntry {
+	var entries []models.LedgerEntry
+	fromMembers := slices.Concat(from.ActiveMembers, from.InactiveMembers)
+	toMembers := slices.Concat(to.ActiveMembers, to.InactiveMembers)
+	var ids []string
+	for _, m := range slices.Concat(fromMembers, toMembers) {
+		if !slices.Contains(ids, m.ID) {
+			ids = append(ids, m.ID)
+		}
+	}
+	for _, id := range ids {
+		var old, now models.Member
+		if i := FindMember(fromMembers, id); i >= 0 {
+			old = fromMembers[i]
+		}
+		if i := FindMember(toMembers, id); i >= 0 {
+			now = toMembers[i]
+		}
+		entry := models.LedgerEntry{MemberID: id, Member: cmp.Or(now.Name, old.Name), Coins: coinChange(old.Coins, now.Coins), XP: now.XP - old.XP}
+		if now.Level != old.Level {
+			entry.Level = now.Level
+		}
+		if entry.Coins != nil || entry.XP != 0 || entry.Level != 0 {
+			entries = append(entries, entry)
+		}
+	}
+	if coins := coinChange(from.Treasury, to.Treasury); coins != nil {
+		entries = append(entries, models.LedgerEntry{Member: models.TreasuryName, Coins: coins})
+	}
+	return entries
+}
+
+// Returns how many of each coin were gained or lost going from one purse to another, or nil if nothing changed
+func coinChange(from map[string]int, to map[string]int) map[string]int {
+	change := make(map[string]int)
+	for coinType, amount := range to {
+		change[coinType] += amount
+	}
+	for coinType, amount := range from {
+		change[coinType] -= amount
+	}
+	maps.DeleteFunc(change, func(_ string, amount int) bool { return amount == 0 })
+	if len(change) == 0 {
+		return nil
+	}
+	return change
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Level: 1, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", Level: 1, Coins: make(map[string]int)},
+		},
+	}
+	var history models.History
+
+	Record(&history, &party, models.CoinAward)
+	DistributeCoins(&party, map[string]int{models.Gold: 5000})
+	Record(&history, &party, models.XPAward)
+	DistributeExperience(&party, 600)
+
+	// Undo both changes
+	if _, ok := Undo(&history, &party); !ok {
+		t.Fatal("Expected to undo the XP award")
+	}
+	if xp := getMemberByName(party.ActiveMembers, "Keg").XP; xp != 0 {
+		t.Errorf("Expected XP to be undone, got %d", xp)
+	}
+	tx, ok := Undo(&history, &party)
+	if !ok {
+		t.Fatal("Expected to undo the coin award")
+	}
+	if tx.Type != models.Undo || tx.Reason != models.CoinAward {
+		t.Errorf("Expected an undo transaction for %s, got %s for %s", models.CoinAward, tx.Type, tx.Reason)
+	}
+	if gold := getMemberByName(party.ActiveMembers, "Keg").Coins[models.Gold]; gold != 0 {
+		t.Errorf("Expected gold to be undone, got %d", gold)
+	}
+	if len(tx.Entries) != 2 || tx.Entries[0].Coins[models.Gold] != -2500 || tx.Entries[1].Coins[models.Gold] != -2500 {
+		t.Errorf("Expected the undo to take back 2500 gold from each member, got %+v", tx.Entries)
+	}
+	if _, ok := Undo(&history, &party); ok {
+		t.Error("Expected nothing left to undo")
+	}
+
+	// Redo the coin award only
+	if _, ok := Redo(&history, &party); !ok {
+		t.Fatal("Expected to redo the coin award")
+	}
+	if gold := getMemberByName(party.ActiveMembers, "Keg").Coins[models.Gold]; gold != 2500 {
+		t.Errorf("Expected 2500 gold after redo, got %d", gold)
+	}
+
+	// A new action clears the redo stack
+	Record(&history, &party, models.MemberAdded)
+	AddMember(&party, "Fred", 0, map[string]int{})
+	if _, ok := Redo(&history, &party); ok {
+		t.Error("Expected redo stack to be cleared by a new action")
+	}
+}
+
+func TestUndoFollowsXPTable(t *testing.T) {
+	party := models.Party{ActiveMembers: []models.Member{{ID: "keg", Name: "Keg", Level: 1, Share: 1}}}
+	var history models.History
+
+	Record(&history, &party, models.XPAward)
+	DistributeExperience(&party, 1000)
+	Record(&history, &party, models.XPAward)
+	DistributeExperience(&party, 500)
+
+	fast, _ := models.FindXPTable("pf1-fast")
+	models.CurrentXPTable = fast
+	t.Cleanup(func() { models.CurrentXPTable = models.XPTables[0] })
+	UpdateLevels(&party)
+	if keg := party.ActiveMembers[0]; keg.Level != 2 {
+		t.Fatalf("Expected 1500 XP to be level 2 on the fast track, got %d", keg.Level)
+	}
+
+	// The snapshot was taken at level 3 under the 5e table
+	Undo(&history, &party)
+	if keg := party.ActiveMembers[0]; keg.XP != 1000 || keg.Level != 1 {
+		t.Errorf("Expected 1000 XP at level 1 on the fast track after undo, got %+v", keg)
+	}
+	Redo(&history, &party)
+	if keg := party.ActiveMembers[0]; keg.XP != 1500 || keg.Level != 2 {
+		t.Errorf("Expected 1500 XP at level 2 on the fast track after redo, got %+v", keg)
+	}
+}
+
+func TestLedgerAddsUpAfterUndo(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{{ID: "keg", Name: "Keg", Level: 1, Share: 1, Coins: make(map[string]int)}},
+		Treasury:      make(map[string]int),
+	}
+	var history models.History
+	var ledger []models.Transaction
+
+	Record(&history, &party, models.CoinAward)
+	ledger = append(ledger, ApplyCoinPlan(&party, PlanCoinDistribution(&party, map[string]int{models.Gold: 40}, CoinOptions{TreasuryPercent: 25})))
+	Record(&history, &party, models.XPAward)
+	tx, _ := DistributeExperience(&party, 1000)
+	ledger = append(ledger, tx)
+	tx, _ = Undo(&history, &party)
+	ledger = append(ledger, tx)
+	tx, _ = Undo(&history, &party)
+	ledger = append(ledger, tx)
+	tx, _ = Redo(&history, &party)
+	ledger = append(ledger, tx)
+
+	gold, treasury, xp := 0, 0, 0
+	for _, tx := range ledger {
+		for _, e := range tx.Entries {
+			if e.Member == models.TreasuryName {
+				treasury += e.Coins[models.Gold]
+			} else {
+				gold += e.Coins[models.Gold]
+				xp += e.XP
+			}
+		}
+	}
+	keg := party.ActiveMembers[0]
+	if gold != keg.Coins[models.Gold] || treasury != party.Treasury[models.Gold] || xp != keg.XP {
+		t.Errorf("Ledger adds up to %d gold, %d in the treasury and %d XP, but the party has %+v and %v", gold, treasury, xp, keg, party.Treasury)
+	}
+	if keg.Coins[models.Gold] != 30 || party.Treasury[models.Gold] != 10 || keg.XP != 0 {
+		t.Errorf("Expected the coin award to be redone without the XP, got %+v and %v", keg, party.Treasury)
+	}
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// ErrItemNotFound is returned when an item ID doesn't match anything in the loot pool or an inventory
+var ErrItemNotFound = errors.New("item not found")
+
+// AddLoot puts a newly found item in the party loot pool, stacking it with an identical item already there
+func AddLoot(p *models.Party, item models.Item) (models.Transaction, error) {
+	item.Name = strings.TrimSpace(item.Name)
+	if err := validateItem(item); err != nil {
+		return models.Transaction{}, err
+	}
+	item.ID = models.NewID()
+	item.Attuned = false
+	p.Loot = stackItem(p.Loot, item)
+	log.Printf("The party found %d %s\n", item.Quantity, item.Name)
+
+	tx := models.NewTransaction(models.LootAdded, "")
+	tx.Entries = append(tx.Entries, models.LedgerEntry{Member: models.LootName, Item: item.Name, Quantity: item.Quantity})
+	return tx, nil
+}
+
+// AssignItem moves some or all of a stack in the loot pool to a member's inventory
+func AssignItem(p *models.Party, itemID string, memberID string, quantity int) (models.Transaction, error) {
+	member := findAnyMember(p, memberID)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, memberID)
+	}
+	item, err := takeItem(&p.Loot, itemID, quantity)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	member.Items = stackItem(member.Items, item)
+	log.Printf("%s took %d %s\n", member.Name, quantity, item.Name)
+
+	tx := models.NewTransaction(models.ItemAssigned, "")
+	tx.Entries = append(tx.Entries,
+		models.LedgerEntry{Member: models.LootName, Item: item.Name, Quantity: -quantity},
+		models.LedgerEntry{MemberID: member.ID, Member: member.Name, Item: item.Name, Quantity: quantity})
+	return tx, nil
+}
+
+// ReturnItem moves some or all of a stack in a member's inventory back to the loot pool.
+// Returned items are no longer attuned.
+func ReturnItem(p *models.Party, memberID string, itemID string, quantity int) (models.Transaction, error) {
+	member := findAnyMember(p, memberID)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, memberID)
+	}
+	item, err := takeItem(&member.Items, itemID, quantity)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	item.Attuned = false
+	p.Loot = stackItem(p.Loot, item)
+	log.Printf("%s put %d %s back in the loot pool\n", member.Name, quantity, item.Name)
+
+	tx := models.NewTransaction(models.ItemReturned, "")
+	tx.Entries = append(tx.Entries,
+		models.LedgerEntry{MemberID: member.ID, Member: member.Name, Item: item.Name, Quantity: -quantity},
+		models.LedgerEntry{Member: models.LootName, Item: item.Name, Quantity: quantity})
+	return tx, nil
+}
+
+// SetAttunement attunes a member to an item in their inventory, or ends the attunement.
+// A member can only be attuned to models.MaxAttuned items at once.
+func SetAttunement(p *models.Party, memberID string, itemID string, attuned bool) (models.Transaction, error) {
+	member := findAnyMember(p, memberID)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, memberID)
+	}
+	i := slices.IndexFunc(member.Items, func(item models.Item) bool { return item.ID == itemID })
+	if i < 0 {
+		return models.Transaction{}, fmt.Errorf("%w: %s has no item with ID %s", ErrItemNotFound, member.Name, itemID)
+	}
+	if attuned && !member.Items[i].Attuned {
+		if count := AttunedCount(*member); count >= models.MaxAttuned {
+			return models.Transaction{}, fmt.Errorf("%s is already attuned to %d items", member.Name, count)
+		}
+	}
+	member.Items[i].Attuned = attuned
+
+	reason := "Attuned"
+	if !attuned {
+		reason = "Attunement ended"
+	}
+	tx := models.NewTransaction(models.ItemAttuned, reason)
+	tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: member.ID, Member: member.Name, Item: member.Items[i].Name})
+	return tx, nil
+}
+
+// AttunedCount returns how many items a member is attuned to
+func AttunedCount(member models.Member) int {
+	count := 0
+	for _, item := range member.Items {
+		if item.Attuned {
+			count++
+		}
+	}
+	return count
+}
+
+// Checks that an item can be added to the loot pool
+func validateItem(item models.Item) error {
+	switch {
+	case item.Name == "":
+		return errors.New("an item needs a name")
+	case item.Quantity < 1:
+		return errors.New("quantity must be at least 1")
+	case item.ValueGP < 0:
+		return errors.New("value can't be negative")
+	case item.Weight < 0:
+		return errors.New("weight can't be negative")
+	}
+	return nil
+}
+
+// Removes quantity of an item from a stack list and returns them as a stack of their own.
+// A whole stack keeps its ID, part of a stack gets a new one.
+func takeItem(items *[]models.Item, itemID string, quantity int) (models.Item, error) {
+	i := slices.IndexFunc(*items, func(item models.Item) bool { return item.ID == itemID })
+	if i < 0 {
+		return models.Item{}, fmt.Errorf("%w: no item with ID %s", ErrItemNotFound, itemID)
+	}
+	item := (*items)[i]
+	if quantity < 1 || quantity > item.Quantity {
+		return models.Item{}, fmt.Errorf("quantity must be between 1 and %d", item.Quantity)
+	}
+	if quantity == item.Quantity {
+		*items = slices.Delete(*items, i, i+1)
+		return item, nil
+	}
+	(*items)[i].Quantity -= quantity
+	item.ID = models.NewID()
+	item.Quantity = quantity
+	return item, nil
+}
+
+// Adds a stack to a list, merging it into an identical stack that isn't attuned
+func stackItem(items []models.Item, item models.Item) []models.Item {
+	for i, existing := range items {
+		if !existing.Attuned && !item.Attuned && existing.Name == item.Name && existing.ValueGP == item.ValueGP &&
+			existing.Weight == item.Weight && existing.Notes == item.Notes {
+			items[i].Quantity += item.Quantity
+			return items
+		}
+	}
+	return append(items, item)
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"testing"
+)
+
+func TestLootAndInventory(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", Coins: make(map[string]int)},
+		},
+	}
+
+	if _, err := AddLoot(&party, models.Item{Name: "Ruby", Quantity: 3, ValueGP: 500}); err != nil {
+		t.Fatal(err)
+	}
+	// An identical find stacks with the rubies already in the pool
+	tx, err := AddLoot(&party, models.Item{Name: "Ruby", Quantity: 1, ValueGP: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.Loot) != 1 || party.Loot[0].Quantity != 4 {
+		t.Fatalf("Expected a stack of 4 rubies, got %+v", party.Loot)
+	}
+	if tx.Entries[0].Member != models.LootName || tx.Entries[0].Quantity != 1 {
+		t.Errorf("Unexpected ledger entry for new loot: %+v", tx.Entries)
+	}
+	for _, invalid := range []models.Item{{Name: " ", Quantity: 1}, {Name: "Ruby", Quantity: 0}, {Name: "Ruby", Quantity: 1, ValueGP: -1}} {
+		if _, err := AddLoot(&party, invalid); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+
+	ruby := party.Loot[0].ID
+	if _, err := AssignItem(&party, ruby, "keg", 5); err == nil {
+		t.Error("Expected assigning more rubies than there are to fail")
+	}
+	if _, err := AssignItem(&party, ruby, "keg", 3); err != nil {
+		t.Fatal(err)
+	}
+	keg := &party.ActiveMembers[0]
+	if len(keg.Items) != 1 || keg.Items[0].Quantity != 3 || keg.Items[0].ID == ruby || party.Loot[0].Quantity != 1 {
+		t.Errorf("Expected Keg to get a new stack of 3 rubies and 1 to stay in the pool, got %+v and %+v", keg.Items, party.Loot)
+	}
+	// The last ruby moves as a whole stack and joins Rowan's inventory
+	if _, err := AssignItem(&party, ruby, "rowan", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(party.Loot) != 0 || party.ActiveMembers[1].Items[0].ID != ruby {
+		t.Errorf("Expected the pool to be empty, got %+v", party.Loot)
+	}
+
+	if _, err := ReturnItem(&party, "keg", keg.Items[0].ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if keg.Items[0].Quantity != 1 || party.Loot[0].Quantity != 2 {
+		t.Errorf("Expected 2 rubies back in the pool, got %+v and %+v", keg.Items, party.Loot)
+	}
+	if _, err := ReturnItem(&party, "keg", "missing", 1); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected returning a missing item to fail, got %v", err)
+	}
+}
+
+func TestAttunement(t *testing.T) {
+	party := models.Party{ActiveMembers: []models.Member{{ID: "keg", Name: "Keg"}}}
+	for _, name := range []string{"Flame Tongue", "Cloak of Protection", "Ring of Protection", "Boots of Speed"} {
+		if _, err := AddLoot(&party, models.Item{Name: name, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := AssignItem(&party, party.Loot[0].ID, "keg", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items := party.ActiveMembers[0].Items
+	for _, item := range items[:models.MaxAttuned] {
+		if _, err := SetAttunement(&party, "keg", item.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := SetAttunement(&party, "keg", items[3].ID, true); err == nil {
+		t.Error("Expected a fourth attunement to fail")
+	}
+
+	// Returning an item ends the attunement and frees a slot
+	if _, err := ReturnItem(&party, "keg", items[0].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if party.Loot[0].Attuned {
+		t.Error("Expected the returned item not to be attuned")
+	}
+	if _, err := SetAttunement(&party, "keg", party.ActiveMembers[0].Items[2].ID, true); err != nil {
+		t.Error(err)
+	}
+	if count := AttunedCount(party.ActiveMembers[0]); count != models.MaxAttuned {
+		t.Errorf("Expected %d attuned items, got %d", models.MaxAttuned, count)
+	}
+}
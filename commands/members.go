@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// What happens to the coins of a member who is deleted
+const (
+	DiscardWallet  = iota // the coins leave the party with them
+	SplitWallet           // the coins are distributed among the remaining active members
+	TreasuryWallet        // the coins go to the party treasury
+)
+
+// MemberEdit holds the new values for a member's editable fields
+type MemberEdit struct {
+	Name  string
+	XP    int
+	Share float64
+	Coins map[string]int
+}
+
+// EditMember replaces a member's name, XP, share weight and wallet. The level is worked out again from the new XP,
+// unless the campaign levels by milestone. The ledger records how much each value changed.
+func EditMember(p *models.Party, id string, edit MemberEdit) (models.Transaction, error) {
+	if err := validateEdit(edit); err != nil {
+		return models.Transaction{}, err
+	}
+	member := findAnyMember(p, id)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, id)
+	}
+
+	entry := models.LedgerEntry{MemberID: id, Member: edit.Name, XP: edit.XP - member.XP, Coins: make(map[string]int)}
+	for _, coinType := range models.CoinOrder {
+		if diff := edit.Coins[coinType] - member.Coins[coinType]; diff != 0 {
+			entry.Coins[coinType] = diff
+		}
+	}
+	reason := ""
+	if edit.Name != member.Name {
+		reason = fmt.Sprintf("Renamed from %s", member.Name)
+		log.Printf("%s is now called %s\n", member.Name, edit.Name)
+	}
+
+	member.Name = edit.Name
+	member.XP = edit.XP
+	member.Share = edit.Share
+	if !models.UsesMilestones() {
+		member.Level = determineLevel(edit.XP)
+	}
+	member.Coins = maps.Clone(edit.Coins)
+
+	tx := models.NewTransaction(models.MemberEdited, reason)
+	tx.Entries = append(tx.Entries, entry)
+	return tx, nil
+}
+
+// DeleteMember removes a member from the party for good. Their wallet is discarded,
+// split among the remaining active members as if it were new loot, or put in the treasury.
+// Their items always go back to the loot pool.
+func DeleteMember(p *models.Party, id string, wallet int) (models.Transaction, error) {
+	group, remainingActive := &p.ActiveMembers, len(p.ActiveMembers)-1
+	index := FindMember(*group, id)
+	if index < 0 {
+		group, remainingActive = &p.InactiveMembers, len(p.ActiveMembers)
+		index = FindMember(*group, id)
+	}
+	if index < 0 {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, id)
+	}
+	if wallet == SplitWallet && remainingActive == 0 {
+		return models.Transaction{}, errors.New("there are no other active members to split the wallet with")
+	}
+	removed := (*group)[index]
+
+	*group = slices.Delete(*group, index, index+1)
+	normalizeCoinPriority(*group)
+	forgetDebts(p, id)
+	log.Printf("Farewell %s\n", removed.Name)
+
+	tx := models.NewTransaction(models.MemberRemoved, "")
+	entry := models.LedgerEntry{MemberID: removed.ID, Member: removed.Name, Coins: make(map[string]int)}
+	for coinType, amount := range removed.Coins {
+		if amount != 0 {
+			entry.Coins[coinType] = -amount
+		}
+	}
+	tx.Entries = append(tx.Entries, entry)
+
+	switch wallet {
+	case SplitWallet:
+		tx.Reason = fmt.Sprintf("%s's wallet split among the party", removed.Name)
+		split := DistributeCoins(p, removed.Coins)
+		tx.Entries = append(tx.Entries, split.Entries...)
+	case TreasuryWallet:
+		tx.Reason = fmt.Sprintf("%s's wallet put in the treasury", removed.Name)
+		addToTreasury(p, removed.Coins)
+		tx.Entries = append(tx.Entries, models.LedgerEntry{Member: models.TreasuryName, Coins: maps.Clone(removed.Coins)})
+	}
+	for _, item := range removed.Items {
+		item.Attuned = false
+		p.Loot = stackItem(p.Loot, item)
+		tx.Entries = append(tx.Entries, models.LedgerEntry{Member: models.LootName, Item: item.Name, Quantity: item.Quantity})
+	}
+	return tx, nil
+}
+
+// Returns the active or inactive member with the given ID, or nil if there isn't one
+func findAnyMember(p *models.Party, id string) *models.Member {
+	if i := FindMember(p.ActiveMembers, id); i >= 0 {
+		return &p.ActiveMembers[i]
+	}
+	if i := FindMember(p.InactiveMembers, id); i >= 0 {
+		return &p.InactiveMembers[i]
+	}
+	return nil
+}
+
+// Renumbers coin priorities from 0 without changing their order, closing any gaps
+func normalizeCoinPriority(members []models.Member) {
+	order := make([]int, len(members))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return members[order[a]].CoinPriority < members[order[b]].CoinPriority
+	})
+	for priority, i := range order {
+		members[i].CoinPriority = priority
+	}
+}
+
+func validateEdit(edit MemberEdit) error {
+	if edit.Name == "" {
+		return errors.New("name can't be empty")
+	}
+	if edit.XP < 0 {
+		return errors.New("XP can't be negative")
+	}
+	if edit.Share < models.MinShare {
+		return fmt.Errorf("share must be at least %g", models.MinShare)
+	}
+	for _, coinType := range models.CoinOrder {
+		if edit.Coins[coinType] < 0 {
+			return fmt.Errorf("%s can't be negative", coinType)
+		}
+	}
+	return nil
+}
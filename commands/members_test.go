@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"testing"
+)
+
+func TestEditMember(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Kegg", Level: 1, XP: 100, Coins: map[string]int{models.Gold: 10}},
+		},
+	}
+
+	tx, err := EditMember(&party, "keg", MemberEdit{Name: "Keg", XP: 1000, Share: 0.5, Coins: map[string]int{models.Gold: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keg := party.ActiveMembers[0]
+	if keg.Name != "Keg" || keg.XP != 1000 || keg.Level != 3 || keg.Share != 0.5 || keg.Coins[models.Gold] != 4 {
+		t.Errorf("Unexpected member after edit: %+v", keg)
+	}
+	if entry := tx.Entries[0]; entry.XP != 900 || entry.Coins[models.Gold] != -6 {
+		t.Errorf("Expected the ledger to record +900 XP and -6 gold, got %+v", entry)
+	}
+
+	// Invalid edits change nothing
+	invalid := []MemberEdit{
+		{Name: "", XP: 0, Share: 1},
+		{Name: "Keg", XP: -1, Share: 1},
+		{Name: "Keg", Share: 0},
+		{Name: "Keg", Share: 0.001},
+		{Name: "Keg", Share: 1, Coins: map[string]int{models.Silver: -3}},
+	}
+	for _, edit := range invalid {
+		if _, err := EditMember(&party, "keg", edit); err == nil {
+			t.Errorf("Expected %+v to be rejected", edit)
+		}
+	}
+	if party.ActiveMembers[0].Name != "Keg" {
+		t.Error("A rejected edit changed the member")
+	}
+
+	if _, err := EditMember(&party, "nobody", MemberEdit{Name: "X"}); err == nil {
+		t.Error("Expected an error editing a missing member")
+	}
+}
+
+func TestDeleteMember(t *testing.T) {
+	newParty := func() models.Party {
+		return models.Party{
+			ActiveMembers: []models.Member{
+				{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: map[string]int{models.Gold: 7}, Items: []models.Item{{ID: "axe", Name: "Greataxe", Quantity: 1, Attuned: true}}},
+				{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+				{ID: "fred", Name: "Fred", CoinPriority: 2, Coins: make(map[string]int)},
+			},
+		}
+	}
+
+	t.Run("split", func(t *testing.T) {
+		party := newParty()
+		if _, err := DeleteMember(&party, "keg", SplitWallet); err != nil {
+			t.Fatal(err)
+		}
+		if len(party.ActiveMembers) != 2 {
+			t.Fatalf("Expected 2 members left, got %d", len(party.ActiveMembers))
+		}
+		rowan := getMemberByName(party.ActiveMembers, "Rowan")
+		fred := getMemberByName(party.ActiveMembers, "Fred")
+		if rowan.Coins[models.Gold]+fred.Coins[models.Gold] != 7 {
+			t.Errorf("Expected Keg's 7 gold to be split, got %d and %d", rowan.Coins[models.Gold], fred.Coins[models.Gold])
+		}
+		if len(party.Loot) != 1 || party.Loot[0].Name != "Greataxe" || party.Loot[0].Attuned {
+			t.Errorf("Expected Keg's greataxe back in the loot pool unattuned, got %+v", party.Loot)
+		}
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		party := newParty()
+		if _, err := DeleteMember(&party, "keg", DiscardWallet); err != nil {
+			t.Fatal(err)
+		}
+		if getMemberByName(party.ActiveMembers, "Rowan").Coins[models.Gold] != 0 || party.Treasury[models.Gold] != 0 {
+			t.Error("Expected the wallet to be discarded")
+		}
+		if len(party.Loot) != 1 || party.Loot[0].Name != "Greataxe" {
+			t.Errorf("Expected Keg's greataxe back in the loot pool even with the wallet discarded, got %+v", party.Loot)
+		}
+		// Priorities are renumbered without gaps
+		if getMemberByName(party.ActiveMembers, "Rowan").CoinPriority != 0 || getMemberByName(party.ActiveMembers, "Fred").CoinPriority != 1 {
+			t.Errorf("Expected priorities 0 and 1, got %+v", party.ActiveMembers)
+		}
+	})
+
+	t.Run("treasury", func(t *testing.T) {
+		party := newParty()
+		if _, err := DeleteMember(&party, "keg", TreasuryWallet); err != nil {
+			t.Fatal(err)
+		}
+		if party.Treasury[models.Gold] != 7 {
+			t.Errorf("Expected Keg's 7 gold in the treasury, got %v", party.Treasury)
+		}
+	})
+
+	t.Run("last member", func(t *testing.T) {
+		party := models.Party{ActiveMembers: []models.Member{{ID: "keg", Name: "Keg"}}}
+		if _, err := DeleteMember(&party, "keg", SplitWallet); err == nil {
+			t.Error("Expected an error splitting a wallet with nobody")
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// ErrNotMilestones is returned when levelling a character directly in a campaign that levels by XP
+var ErrNotMilestones = errors.New("this campaign levels characters by XP, switch it to milestone leveling first")
+
+// LevelUp raises the active members with the given IDs, or every active member if ids is nil,
+// a level for a milestone reached in the given session (0 if it isn't known).
+// Members already at the highest level are left alone.
+func LevelUp(p *models.Party, ids []string, session int, reason string) (models.Transaction, error) {
+	if !models.UsesMilestones() {
+		return models.Transaction{}, ErrNotMilestones
+	}
+	if session < 0 {
+		return models.Transaction{}, errors.New("session can't be negative")
+	}
+	tx := models.NewTransaction(models.LevelChanged, milestoneReason(session, reason))
+	for i := range p.ActiveMembers {
+		member := &p.ActiveMembers[i]
+		if (ids == nil || slices.Contains(ids, member.ID)) && member.Level < MaxLevel() {
+			tx.Entries = append(tx.Entries, reachMilestone(member, member.Level+1, session, reason, tx))
+		}
+	}
+	if len(tx.Entries) == 0 {
+		return models.Transaction{}, errors.New("nobody could go up a level")
+	}
+	return tx, nil
+}
+
+// SetLevel puts an active or inactive member at the given level, recording it as a milestone
+// reached in the given session (0 if it isn't known)
+func SetLevel(p *models.Party, id string, level int, session int, reason string) (models.Transaction, error) {
+	if !models.UsesMilestones() {
+		return models.Transaction{}, ErrNotMilestones
+	}
+	if level < 1 || level > MaxLevel() {
+		return models.Transaction{}, fmt.Errorf("level must be from 1 to %d", MaxLevel())
+	}
+	if session < 0 {
+		return models.Transaction{}, errors.New("session can't be negative")
+	}
+	member := findAnyMember(p, id)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, id)
+	}
+	if member.Level == level {
+		return models.Transaction{}, fmt.Errorf("%s is already level %d", member.Name, level)
+	}
+
+	tx := models.NewTransaction(models.LevelChanged, milestoneReason(session, reason))
+	tx.Entries = append(tx.Entries, reachMilestone(member, level, session, reason, tx))
+	return tx, nil
+}
+
+// Moves a member to a new level and adds it to their level history
+func reachMilestone(member *models.Member, level int, session int, reason string, tx models.Transaction) models.LedgerEntry {
+	member.Level = level
+	member.Milestones = append(member.Milestones, models.Milestone{Timestamp: tx.Timestamp, Level: level, Session: session, Reason: reason})
+	log.Printf("%s reached Level %d\n", member.Name, level)
+	return models.LedgerEntry{MemberID: member.ID, Member: member.Name, Level: level}
+}
+
+// Returns the ledger reason for a milestone, noting the session it happened in
+func milestoneReason(session int, reason string) string {
+	switch {
+	case session == 0:
+		return reason
+	case reason == "":
+		return fmt.Sprintf("Session %d", session)
+	default:
+		return fmt.Sprintf("Session %d: %s", session, reason)
+	}
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"testing"
+)
+
+// Switches the package to milestone leveling for the rest of a test
+func useMilestones(t *testing.T) {
+	t.Helper()
+	models.Leveling = models.MilestoneLeveling
+	t.Cleanup(func() { models.Leveling = models.XPLeveling })
+}
+
+func TestLevelUp(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Level: 3},
+			{ID: "rowan", Name: "Rowan", Level: 20},
+			{ID: "fred", Name: "Fred", Level: 3},
+		},
+	}
+	if _, err := LevelUp(&party, nil, 4, ""); !errors.Is(err, ErrNotMilestones) {
+		t.Fatalf("Expected levelling up by XP to be refused, got %v", err)
+	}
+	useMilestones(t)
+
+	tx, err := LevelUp(&party, nil, 4, "Freed the mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Reason != "Session 4: Freed the mine" || len(tx.Entries) != 2 {
+		t.Errorf("Expected Keg and Fred to level in session 4, got %+v", tx)
+	}
+	keg, rowan := party.ActiveMembers[0], party.ActiveMembers[1]
+	if keg.Level != 4 || len(keg.Milestones) != 1 || keg.Milestones[0].Session != 4 || keg.Milestones[0].Level != 4 {
+		t.Errorf("Expected Keg to reach level 4 in session 4, got %+v", keg)
+	}
+	if rowan.Level != 20 || len(rowan.Milestones) != 0 {
+		t.Errorf("Expected Rowan to stay at the highest level, got %+v", rowan)
+	}
+
+	if _, err := LevelUp(&party, []string{"rowan"}, 5, ""); err == nil {
+		t.Error("Expected an error when nobody can level up")
+	}
+	if _, err := LevelUp(&party, []string{"fred"}, 5, ""); err != nil || party.ActiveMembers[2].Level != 5 {
+		t.Errorf("Expected only Fred to level up, got %v and %+v", err, party.ActiveMembers)
+	}
+
+	// XP no longer changes levels
+	DistributeExperience(&party, 100000)
+	if party.ActiveMembers[0].Level != 4 {
+		t.Errorf("Expected XP not to level Keg, got %+v", party.ActiveMembers[0])
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	useMilestones(t)
+	party := models.Party{InactiveMembers: []models.Member{{ID: "keg", Name: "Keg", Level: 1}}}
+
+	for _, level := range []int{0, 1, 21} {
+		if _, err := SetLevel(&party, "keg", level, 0, ""); err == nil {
+			t.Errorf("Expected level %d to be refused", level)
+		}
+	}
+	if _, err := SetLevel(&party, "nobody", 5, 0, ""); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Expected ErrMemberNotFound, got %v", err)
+	}
+	tx, err := SetLevel(&party, "keg", 5, 0, "Joined late")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keg := party.InactiveMembers[0]; keg.Level != 5 || tx.Reason != "Joined late" || tx.Entries[0].Level != 5 {
+		t.Errorf("Expected Keg to be set to level 5, got %+v and %+v", keg, tx)
+	}
+
+	// Editing XP keeps the level
+	if _, err := EditMember(&party, "keg", MemberEdit{Name: "Keg", XP: 0, Share: 1}); err != nil || party.InactiveMembers[0].Level != 5 {
+		t.Errorf("Expected editing Keg to keep level 5, got %v and %+v", err, party.InactiveMembers[0])
+	}
+}
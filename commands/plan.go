@@ -0,0 +1,397 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// MemberShare is what a single member receives from a coin distribution
+type MemberShare struct {
+	ID           string
+	Name         string
+	Coins        map[string]int // every coin the member receives
+	Extra        map[string]int // the part of Coins that came from remainders
+	CoinPriority int            // the member's coin priority after the distribution
+	Share        float64        // the member's share weight
+	Items        []models.Item  // loot pool items the member takes as part of their share
+	ItemValue    int            // what Items are worth, in the currency's smallest coin
+	FairValue    int            // the member's share of the coins and items together, in the smallest coin
+}
+
+// Ways a coin distribution can be split among the members
+const (
+	SplitByCoin  = iota // each coin type is shared out on its own
+	SplitByValue        // everyone gets the same total value, breaking coins into change where needed
+	SplitExact          // each coin type is shared out on its own, with leftovers broken into smaller coins
+)
+
+// CoinOptions controls how a coin distribution is worked out
+type CoinOptions struct {
+	Strategy        int
+	Participants    []string // IDs of the active members sharing the coins, nil for all of them
+	TreasuryPercent int      // how much of each coin type goes to the treasury before the split
+	SettleDebts     bool     // pay back loans out of the borrowers' shares
+}
+
+// Settlement is part of a member's share that goes to someone they owe instead
+type Settlement struct {
+	BorrowerID string
+	Borrower   string
+	LenderID   string
+	Lender     string
+	Coins      map[string]int
+}
+
+// CoinExchange records coins that were broken into smaller ones to make a distribution work
+type CoinExchange struct {
+	From  string
+	To    string
+	Count int // how many From coins were broken
+}
+
+func (e CoinExchange) String() string {
+	return fmt.Sprintf("%d %s broken into %d %s", e.Count, e.From, e.Count*models.CoinValues[e.From]/models.CoinValues[e.To], e.To)
+}
+
+// CoinPlan is the outcome of a coin distribution, worked out before anything is changed
+type CoinPlan struct {
+	Money       map[string]int
+	Strategy    int
+	Treasury    map[string]int // the part of Money set aside for the treasury
+	Shares      []MemberShare
+	Exchanges   []CoinExchange
+	Settlements []Settlement
+}
+
+// PlanCoinDistribution works out how money would be split among the active members
+// without changing the party
+func PlanCoinDistribution(p *models.Party, money map[string]int, opts CoinOptions) CoinPlan {
+	plan := newPlan(p, money, opts)
+	if len(plan.Shares) == 0 {
+		return plan
+	}
+
+	switch opts.Strategy {
+	case SplitByValue:
+		planByValue(&plan)
+	case SplitExact:
+		planExact(&plan)
+	default:
+		planByCoin(&plan)
+	}
+	if opts.SettleDebts {
+		planSettlements(&plan, p)
+	}
+	return plan
+}
+
+// Sets up a plan with the treasury's cut and an empty share for each member taking part
+func newPlan(p *models.Party, money map[string]int, opts CoinOptions) CoinPlan {
+	plan := CoinPlan{Money: money, Strategy: opts.Strategy, Treasury: treasuryCut(money, opts.TreasuryPercent)}
+	members := participants(p, opts.Participants)
+	if len(members) == 0 {
+		return plan
+	}
+
+	plan.Shares = make([]MemberShare, len(members))
+	for i, member := range members {
+		plan.Shares[i] = MemberShare{
+			ID:           member.ID,
+			Name:         member.Name,
+			Coins:        make(map[string]int),
+			Extra:        make(map[string]int),
+			CoinPriority: member.CoinPriority,
+			Share:        member.Share,
+		}
+	}
+	return plan
+}
+
+// Splits each coin type on its own, handing out what's left over one coin at a time
+func planByCoin(plan *CoinPlan) {
+	weights := shareWeights(plan.Shares)
+
+	// Helper function to plan a specific coin type
+	planCoin := func(coinType string, coinAmount int) {
+		// Excess coins go out in priority order
+		cuts, extras := splitByWeight(coinAmount, weights, priorityOrder(plan.Shares))
+		for i := range plan.Shares {
+			plan.Shares[i].Coins[coinType] += cuts[i]
+			if extras[i] > 0 {
+				plan.Shares[i].Extra[coinType] += extras[i]
+			}
+		}
+
+		// Rotate priority to balance future distributions
+		shiftPriority(plan, 1)
+	}
+
+	// Plan coins in the predefined order
+	split := plan.splitMoney()
+	for _, coinType := range models.CoinOrder {
+		amount, exists := split[coinType]
+		if exists {
+			planCoin(coinType, amount)
+		}
+	}
+}
+
+// Gives every member the same total value for their share. Any copper left over after
+// that goes out one piece at a time in priority order.
+func planByValue(plan *CoinPlan) {
+	pool := plan.splitMoney()
+	total := 0
+	for coinType, amount := range pool {
+		total += amount * models.CoinValues[coinType]
+	}
+
+	order := priorityOrder(plan.Shares)
+	cuts, extras := splitByWeight(total, shareWeights(plan.Shares), order)
+	for _, i := range order {
+		takeValue(plan, pool, &plan.Shares[i], cuts[i]-extras[i])
+	}
+	handedOut := 0
+	for _, i := range order {
+		if extras[i] > 0 {
+			takeValue(plan, pool, &plan.Shares[i], extras[i])
+			plan.Shares[i].Extra[models.CurrentCurrency.Base().Name] += extras[i]
+			handedOut += extras[i]
+		}
+	}
+	// Whoever got the leftovers moves to the back of the line
+	shiftPriority(plan, -handedOut)
+}
+
+// Splits each coin type on its own, largest first. Coins that can't be split evenly are
+// broken into the next smaller coin and split with those, so only copper is ever left over.
+func planExact(plan *CoinPlan) {
+	weights := shareWeights(plan.Shares)
+	pool := plan.splitMoney()
+	for _, coinType := range models.CoinOrder {
+		order := priorityOrder(plan.Shares)
+		cuts, extras := splitByWeight(pool[coinType], weights, order)
+		change, ok := models.CurrentCurrency.ChangeFor(coinType)
+		if !ok {
+			// The smallest coin can't be broken, so what's left over goes out in priority order
+			remainder := 0
+			for i := range plan.Shares {
+				if cuts[i] > 0 {
+					plan.Shares[i].Coins[coinType] += cuts[i]
+				}
+				if extras[i] > 0 {
+					plan.Shares[i].Extra[coinType] += extras[i]
+					remainder += extras[i]
+				}
+			}
+			shiftPriority(plan, -remainder)
+			continue
+		}
+
+		remainder := 0
+		for i := range plan.Shares {
+			if each := cuts[i] - extras[i]; each > 0 {
+				plan.Shares[i].Coins[coinType] += each
+			}
+			remainder += extras[i]
+		}
+		if remainder == 0 {
+			continue
+		}
+		pool[change.Name] += remainder * models.CoinValues[coinType] / change.Value
+		recordExchange(plan, coinType, change.Name, remainder)
+	}
+}
+
+// Moves every share by places in the priority line, wrapping around at the ends.
+// Shares only trade the priorities they already hold between them, so members left
+// out of a distribution keep their place.
+func shiftPriority(plan *CoinPlan, places int) {
+	numMembers := len(plan.Shares)
+	order := priorityOrder(plan.Shares)
+	slots := make([]int, numMembers)
+	for rank, i := range order {
+		slots[rank] = plan.Shares[i].CoinPriority
+	}
+	shift := ((places % numMembers) + numMembers) % numMembers
+	for rank, i := range order {
+		plan.Shares[i].CoinPriority = slots[(rank+shift)%numMembers]
+	}
+}
+
+// Pays back what members owe out of their shares, oldest loan first.
+// Coins in a share are broken into change when they don't cover a debt exactly.
+func planSettlements(plan *CoinPlan, p *models.Party) {
+	for _, d := range p.Debts {
+		i := slices.IndexFunc(plan.Shares, func(s MemberShare) bool { return s.ID == d.BorrowerID })
+		lender := findAnyMember(p, d.LenderID)
+		if i < 0 || lender == nil {
+			continue
+		}
+		share := &plan.Shares[i]
+		amount := min(d.Amount, CoinValue(share.Coins))
+		if amount == 0 {
+			continue
+		}
+
+		repaid := MemberShare{Name: lender.Name, Coins: make(map[string]int)}
+		takeValue(plan, share.Coins, &repaid, amount)
+		for coinType := range share.Extra {
+			share.Extra[coinType] = min(share.Extra[coinType], share.Coins[coinType])
+		}
+		plan.Settlements = append(plan.Settlements, Settlement{
+			BorrowerID: share.ID,
+			Borrower:   share.Name,
+			LenderID:   lender.ID,
+			Lender:     lender.Name,
+			Coins:      repaid.Coins,
+		})
+	}
+}
+
+// Moves coins worth exactly value copper from the pool to the share, largest coins first.
+// Breaks the smallest coin that's too big into change whenever the rest can't make up the value.
+func takeValue(plan *CoinPlan, pool map[string]int, share *MemberShare, value int) {
+	for value > 0 {
+		for _, coinType := range models.CoinOrder {
+			count := min(pool[coinType], value/models.CoinValues[coinType])
+			if count > 0 {
+				pool[coinType] -= count
+				share.Coins[coinType] += count
+				value -= count * models.CoinValues[coinType]
+			}
+		}
+		if value == 0 {
+			return
+		}
+
+		// Every coin left is worth more than what's still owed
+		broken := ""
+		for _, coinType := range models.CoinOrder {
+			if pool[coinType] > 0 {
+				broken = coinType
+			}
+		}
+		if broken == "" {
+			log.Printf("Ran out of coins with %d copper still owed to %s\n", value, share.Name)
+			return
+		}
+		breakCoin(plan, pool, broken)
+	}
+}
+
+// Swaps one coin in the pool for its value in the next smaller coin and records the exchange
+func breakCoin(plan *CoinPlan, pool map[string]int, coinType string) {
+	change, _ := models.CurrentCurrency.ChangeFor(coinType)
+	pool[coinType]--
+	pool[change.Name] += models.CoinValues[coinType] / change.Value
+	recordExchange(plan, coinType, change.Name, 1)
+}
+
+// Adds count broken coins to the plan's exchanges, combining them with earlier ones of the same kind
+func recordExchange(plan *CoinPlan, from string, to string, count int) {
+	for i := range plan.Exchanges {
+		if plan.Exchanges[i].From == from && plan.Exchanges[i].To == to {
+			plan.Exchanges[i].Count += count
+			return
+		}
+	}
+	plan.Exchanges = append(plan.Exchanges, CoinExchange{From: from, To: to, Count: count})
+}
+
+// Returns the coins left to split among the members once the treasury has its cut
+func (plan CoinPlan) splitMoney() map[string]int {
+	split := maps.Clone(plan.Money)
+	for coinType, amount := range plan.Treasury {
+		split[coinType] -= amount
+	}
+	return split
+}
+
+// ApplyCoinPlan adds the treasury's cut to the treasury and each share to the matching
+// member's wallet, and updates coin priorities
+func ApplyCoinPlan(p *models.Party, plan CoinPlan) models.Transaction {
+	tx := models.NewTransaction(models.CoinAward, "")
+	if len(plan.Treasury) > 0 {
+		addToTreasury(p, plan.Treasury)
+		tx.Entries = append(tx.Entries, models.LedgerEntry{Member: models.TreasuryName, Coins: maps.Clone(plan.Treasury)})
+	}
+	if len(plan.Shares) == 0 {
+		log.Println("No members to distribute coins to.")
+		return tx
+	}
+
+	for _, share := range plan.Shares {
+		index := FindMember(p.ActiveMembers, share.ID)
+		if index < 0 {
+			log.Printf("%s is no longer active, skipping their share\n", share.Name)
+			continue
+		}
+		member := &p.ActiveMembers[index]
+		if member.Coins == nil {
+			member.Coins = make(map[string]int)
+		}
+		for _, coinType := range models.CoinOrder {
+			if amount, ok := share.Coins[coinType]; ok {
+				log.Printf("Adding %d of %s to %s's wallet\n", amount, coinType, member.Name)
+				member.Coins[coinType] += amount
+			}
+		}
+		member.CoinPriority = share.CoinPriority
+		tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: member.ID, Member: member.Name, Coins: share.Coins})
+
+		for _, claimed := range share.Items {
+			item, err := takeItem(&p.Loot, claimed.ID, claimed.Quantity)
+			if err != nil {
+				log.Printf("Skipping %s's %s: %v\n", member.Name, claimed.Name, err)
+				continue
+			}
+			member.Items = stackItem(member.Items, item)
+			tx.Entries = append(tx.Entries,
+				models.LedgerEntry{Member: models.LootName, Item: item.Name, Quantity: -item.Quantity},
+				models.LedgerEntry{MemberID: member.ID, Member: member.Name, Item: item.Name, Quantity: item.Quantity})
+		}
+	}
+
+	for _, s := range plan.Settlements {
+		lender := findAnyMember(p, s.LenderID)
+		if lender == nil || FindMember(p.ActiveMembers, s.BorrowerID) < 0 {
+			log.Printf("Skipping %s's repayment to %s\n", s.Borrower, s.Lender)
+			continue
+		}
+		if lender.Coins == nil {
+			lender.Coins = make(map[string]int)
+		}
+		for coinType, amount := range s.Coins {
+			lender.Coins[coinType] += amount
+		}
+		addDebt(p, s.LenderID, s.BorrowerID, -CoinValue(s.Coins))
+		log.Printf("%s paid back %s out of their share\n", s.Borrower, s.Lender)
+		tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: lender.ID, Member: lender.Name, Coins: maps.Clone(s.Coins)})
+	}
+	return tx
+}
+
+// Returns the share weight units of each share
+func shareWeights(shares []MemberShare) []int {
+	weights := make([]int, len(shares))
+	for i, share := range shares {
+		weights[i] = weightUnits(share.Share)
+	}
+	return weights
+}
+
+// Returns share indexes sorted from first to last coin priority
+func priorityOrder(shares []MemberShare) []int {
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return shares[order[a]].CoinPriority < shares[order[b]].CoinPriority
+	})
+	return order
+}
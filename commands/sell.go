@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+)
+
+// What most 5e merchants pay for gear and magic items, as a percentage of the listed value
+const DefaultSalePercent = 50
+
+// Returns the coins merchants pay in, the standard coin and smaller change for anything less
+func merchantCoins() []string {
+	var coins []string
+	for _, coinType := range models.CurrentCurrency.ChangeCoins() {
+		if models.CoinValues[coinType] <= models.CurrentCurrency.StandardCoin().Value {
+			coins = append(coins, coinType)
+		}
+	}
+	return coins
+}
+
+// SaleOptions controls how much an item fetches and who gets the money
+type SaleOptions struct {
+	Percent int  // how much of the listed value the merchant pays
+	Keep    bool // the member selling the item keeps the money instead of splitting it among the active members
+}
+
+// SellItem sells some or all of a stack held by a member, or in the loot pool if holderID is empty.
+// The price is rounded down to the smallest coin and paid in the coins merchants deal in, then either split among
+// the active members like any other haul or put in the seller's wallet. The sale is added to the party's sale history.
+func SellItem(p *models.Party, holderID string, itemID string, quantity int, opts SaleOptions) (models.Transaction, error) {
+	if opts.Percent < 0 || opts.Percent > 100 {
+		return models.Transaction{}, errors.New("the sale percentage must be between 0 and 100")
+	}
+	holderName, items := models.LootName, &p.Loot
+	var seller *models.Member
+	if holderID != "" {
+		if seller = findAnyMember(p, holderID); seller == nil {
+			return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, holderID)
+		}
+		holderName, items = seller.Name, &seller.Items
+	}
+	if opts.Keep && seller == nil {
+		return models.Transaction{}, errors.New("the money from loot pool items has to be split among the party")
+	}
+	if !opts.Keep && len(p.ActiveMembers) == 0 {
+		return models.Transaction{}, errors.New("there are no active members to split the money with")
+	}
+
+	item, err := takeItem(items, itemID, quantity)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	coins := SalePrice(item, quantity, opts.Percent)
+	log.Printf("%s sold %d %s for %d%% of its value\n", holderName, quantity, item.Name, opts.Percent)
+
+	tx := models.NewTransaction(models.ItemSold, "")
+	tx.Entries = append(tx.Entries, models.LedgerEntry{MemberID: holderID, Member: holderName, Item: item.Name, Quantity: -quantity})
+	if opts.Keep {
+		if seller.Coins == nil {
+			seller.Coins = make(map[string]int)
+		}
+		for coinType, amount := range coins {
+			seller.Coins[coinType] += amount
+		}
+		tx.Entries[0].Coins = maps.Clone(coins)
+	} else {
+		tx.Entries = append(tx.Entries, DistributeCoins(p, coins).Entries...)
+	}
+
+	p.Sales = append(p.Sales, models.Sale{
+		Timestamp: time.Now(),
+		Item:      item.Name,
+		Quantity:  quantity,
+		ValueGP:   item.ValueGP,
+		Percent:   opts.Percent,
+		Coins:     coins,
+		SellerID:  holderID,
+		Seller:    holderName,
+		Kept:      opts.Keep,
+	})
+	return tx, nil
+}
+
+// SalePrice returns what a merchant pays for some of a stack at a percentage of its listed value
+func SalePrice(item models.Item, quantity int, percent int) map[string]int {
+	price := item.ValueGP * quantity * models.CurrentCurrency.StandardCoin().Value * percent / 100
+	return coinsFrom(price, merchantCoins())
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"testing"
+)
+
+func TestSellItem(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int), Items: []models.Item{{ID: "sword", Name: "Flame Tongue", Quantity: 1, ValueGP: 5000}}},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+		},
+		Loot: []models.Item{{ID: "ruby", Name: "Ruby", Quantity: 3, ValueGP: 15}},
+	}
+
+	// Two rubies at half value fetch 15 gold, split 8 and 7
+	tx, err := SellItem(&party, "", "ruby", 2, SaleOptions{Percent: DefaultSalePercent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if party.Loot[0].Quantity != 1 {
+		t.Errorf("Expected 1 ruby left in the pool, got %+v", party.Loot)
+	}
+	if keg, rowan := party.ActiveMembers[0], party.ActiveMembers[1]; keg.Coins[models.Gold]+rowan.Coins[models.Gold] != 15 {
+		t.Errorf("Expected 15 gold to be split, got %v and %v", keg.Coins, rowan.Coins)
+	}
+	if tx.Type != models.ItemSold || tx.Entries[0].Quantity != -2 || len(tx.Entries) != 3 {
+		t.Errorf("Unexpected ledger for the sale: %+v", tx)
+	}
+
+	// Keg keeps the money for their sword, sold at 33% of 5000 gp
+	if _, err := SellItem(&party, "keg", "sword", 1, SaleOptions{Percent: 33, Keep: true}); err != nil {
+		t.Fatal(err)
+	}
+	keg := party.ActiveMembers[0]
+	if len(keg.Items) != 0 || keg.Coins[models.Gold] < 1650 {
+		t.Errorf("Expected Keg to be paid 1650 gold, got %v", keg.Coins)
+	}
+
+	if len(party.Sales) != 2 {
+		t.Fatalf("Expected 2 recorded sales, got %+v", party.Sales)
+	}
+	if s := party.Sales[1]; s.Seller != "Keg" || !s.Kept || s.Percent != 33 || s.Coins[models.Gold] != 1650 {
+		t.Errorf("Unexpected sale record: %+v", s)
+	}
+
+	if _, err := SellItem(&party, "", "ruby", 1, SaleOptions{Percent: 50, Keep: true}); err == nil {
+		t.Error("Expected keeping the money for a loot pool item to fail")
+	}
+	if _, err := SellItem(&party, "", "ruby", 1, SaleOptions{Percent: 150}); err == nil {
+		t.Error("Expected a percentage over 100 to fail")
+	}
+	if len(party.Loot) != 1 || len(party.Sales) != 2 {
+		t.Error("A rejected sale changed the party")
+	}
+}
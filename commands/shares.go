@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Share weights are worked with in hundredths so splits come out the same every time
+const shareUnits = 100
+
+// Converts a share weight to whole units. Members without a share set get a full one,
+// and every other member at least one unit so they can't drop out of a split.
+func weightUnits(share float64) int {
+	if share <= 0 {
+		share = models.FullShare
+	}
+	return max(int(math.Round(share*shareUnits)), 1)
+}
+
+// Returns the active members with the given IDs, or every active member if ids is nil
+func participants(p *models.Party, ids []string) []models.Member {
+	if ids == nil {
+		return p.ActiveMembers
+	}
+	var members []models.Member
+	for _, m := range p.ActiveMembers {
+		if slices.Contains(ids, m.ID) {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// splitByWeight divides amount in proportion to weights, rounding everyone down first.
+// The units left over go one at a time to whoever lost the most to rounding, with ties
+// going to whoever comes first in order. Returns each cut and how many leftover units it includes.
+func splitByWeight(amount int, weights []int, order []int) ([]int, []int) {
+	cuts := make([]int, len(weights))
+	extras := make([]int, len(weights))
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 || amount <= 0 {
+		return cuts, extras
+	}
+
+	leftover := amount
+	lost := make([]int, len(weights))
+	for i, w := range weights {
+		cuts[i] = amount * w / total
+		lost[i] = amount * w % total
+		leftover -= cuts[i]
+	}
+
+	byLoss := append([]int(nil), order...)
+	sort.SliceStable(byLoss, func(a, b int) bool { return lost[byLoss[a]] > lost[byLoss[b]] })
+	for _, i := range byLoss[:leftover] {
+		cuts[i]++
+		extras[i]++
+	}
+	return cuts, extras
+}
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Spend takes a price out of a member's wallet, handing back change when the coins in the
+// wallet don't add up to the price exactly. Nothing changes if the wallet can't cover the price.
+func Spend(p *models.Party, id string, price map[string]int) (models.Transaction, error) {
+	member := findAnyMember(p, id)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, id)
+	}
+	paid, change, err := PlanPayment(member.Coins, price)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	entry := models.LedgerEntry{MemberID: member.ID, Member: member.Name, Coins: make(map[string]int)}
+	for _, coinType := range models.CoinOrder {
+		if diff := change[coinType] - paid[coinType]; diff != 0 {
+			member.Coins[coinType] += diff
+			entry.Coins[coinType] = diff
+		}
+	}
+	log.Printf("%s spent %d copper worth of coins\n", member.Name, CoinValue(price))
+
+	tx := models.NewTransaction(models.Purchase, "")
+	tx.Entries = append(tx.Entries, entry)
+	return tx, nil
+}
+
+// PlanPayment works out which coins from a wallet pay a price and what change comes back.
+// Coins are paid largest first without going over the price. If that falls short, the
+// smallest coin left that covers the rest is paid instead of any smaller coins it makes
+// unnecessary, and the difference comes back as change.
+func PlanPayment(wallet map[string]int, price map[string]int) (paid map[string]int, change map[string]int, err error) {
+	owed := 0
+	for _, coinType := range models.CoinOrder {
+		if price[coinType] < 0 {
+			return nil, nil, fmt.Errorf("%s can't be negative", coinType)
+		}
+		owed += price[coinType] * models.CoinValues[coinType]
+	}
+	if have := CoinValue(wallet); have < owed {
+		return nil, nil, fmt.Errorf("%w: the price is %d %s worth but the wallet only holds %d", ErrInsufficientFunds, owed, baseCoinName(), have)
+	}
+
+	paid = make(map[string]int)
+	for _, coinType := range models.CoinOrder {
+		value := models.CoinValues[coinType]
+		if count := min(wallet[coinType], owed/value); count > 0 {
+			paid[coinType] = count
+			owed -= count * value
+		}
+	}
+	if owed == 0 {
+		return paid, make(map[string]int), nil
+	}
+
+	// Every coin left is worth more than what's still owed, so pay with the smallest
+	for i := len(models.CoinOrder) - 1; i >= 0; i-- {
+		coinType := models.CoinOrder[i]
+		if wallet[coinType]-paid[coinType] > 0 {
+			paid[coinType]++
+			owed -= models.CoinValues[coinType]
+			break
+		}
+	}
+	// Take back any smaller coins the change would only hand straight back
+	for i := len(models.CoinOrder) - 1; i >= 0; i-- {
+		coinType := models.CoinOrder[i]
+		value := models.CoinValues[coinType]
+		for paid[coinType] > 0 && -owed >= value {
+			paid[coinType]--
+			owed += value
+		}
+		if paid[coinType] == 0 {
+			delete(paid, coinType)
+		}
+	}
+	return paid, CoinsWorth(-owed), nil
+}
+
+// Returns the name of the smallest coin for messages, e.g. "copper"
+func baseCoinName() string {
+	return strings.ToLower(models.CurrentCurrency.Base().Name)
+}
+
+// CoinsWorth returns the fewest coins worth the given copper, skipping coins like electrum
+// that aren't given as change
+func CoinsWorth(copper int) map[string]int {
+	return coinsFrom(copper, models.CurrentCurrency.ChangeCoins())
+}
+
+// Returns the fewest coins of the given types, largest first, worth the given copper
+func coinsFrom(copper int, coinTypes []string) map[string]int {
+	coins := make(map[string]int)
+	for _, coinType := range coinTypes {
+		value := models.CoinValues[coinType]
+		if count := copper / value; count > 0 {
+			coins[coinType] = count
+			copper -= count * value
+		}
+	}
+	return coins
+}
+
+// CoinValue returns what a set of coins is worth in copper, or whatever the currency's smallest coin is
+func CoinValue(money map[string]int) int {
+	total := 0
+	for coinType, amount := range money {
+		total += amount * models.CoinValues[coinType]
+	}
+	return total
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestPlanPayment(t *testing.T) {
+	tests := []struct {
+		name   string
+		wallet map[string]int
+		price  map[string]int
+		paid   map[string]int
+		change map[string]int
+	}{
+		{
+			name:   "exact coins",
+			wallet: map[string]int{models.Gold: 5, models.Silver: 3},
+			price:  map[string]int{models.Gold: 2, models.Silver: 1},
+			paid:   map[string]int{models.Gold: 2, models.Silver: 1},
+			change: map[string]int{},
+		},
+		{
+			name:   "platinum for 3 gold",
+			wallet: map[string]int{models.Platinum: 1},
+			price:  map[string]int{models.Gold: 3},
+			paid:   map[string]int{models.Platinum: 1},
+			change: map[string]int{models.Gold: 7},
+		},
+		{
+			name:   "gold and silver for 15 copper",
+			wallet: map[string]int{models.Gold: 1, models.Silver: 1},
+			price:  map[string]int{models.Copper: 15},
+			paid:   map[string]int{models.Gold: 1},
+			change: map[string]int{models.Silver: 8, models.Copper: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paid, change, err := PlanPayment(tt.wallet, tt.price)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(paid, tt.paid) || !maps.Equal(change, tt.change) {
+				t.Errorf("Expected to pay %v and get %v back, paid %v and got %v", tt.paid, tt.change, paid, change)
+			}
+		})
+	}
+}
+
+func TestSpend(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Coins: map[string]int{models.Platinum: 1}},
+		},
+	}
+
+	if _, err := Spend(&party, "keg", map[string]int{models.Platinum: 2}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected a purchase Keg can't afford to fail, got %v", err)
+	}
+	tx, err := Spend(&party, "keg", map[string]int{models.Gold: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keg := party.ActiveMembers[0]
+	if keg.Coins[models.Platinum] != 0 || keg.Coins[models.Gold] != 7 {
+		t.Errorf("Expected 7 gold left after paying 3 gold with a platinum, got %v", keg.Coins)
+	}
+	if entry := tx.Entries[0]; entry.Coins[models.Platinum] != -1 || entry.Coins[models.Gold] != 7 {
+		t.Errorf("Expected the ledger to record the platinum paid and the change, got %v", entry.Coins)
+	}
+}
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+)
+
+// Kinds of coin transfer between members
+const (
+	GiftTransfer      = iota // the coins are the recipient's to keep
+	LoanTransfer             // the recipient owes the coins back
+	RepaymentTransfer        // the sender is paying back what they owe the recipient
+)
+
+// Ledger transaction types for each kind of transfer
+var transferTypes = []string{
+	GiftTransfer:      models.Transfer,
+	LoanTransfer:      models.Loan,
+	RepaymentTransfer: models.Repayment,
+}
+
+// TransferCoins moves coins from one member's wallet to another's. Loans are added to what the
+// recipient owes the sender, and repayments taken off what the sender owes the recipient.
+// Only coins actually in the sender's wallet can be handed over, no change is made.
+func TransferCoins(p *models.Party, fromID string, toID string, money map[string]int, kind int) (models.Transaction, error) {
+	if fromID == toID {
+		return models.Transaction{}, errors.New("a member can't transfer coins to themselves")
+	}
+	from := findAnyMember(p, fromID)
+	if from == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, fromID)
+	}
+	to := findAnyMember(p, toID)
+	if to == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, toID)
+	}
+	for _, coinType := range models.CoinOrder {
+		if money[coinType] < 0 {
+			return models.Transaction{}, fmt.Errorf("%s can't be negative", coinType)
+		}
+		if money[coinType] > from.Coins[coinType] {
+			return models.Transaction{}, fmt.Errorf("%w: %s has %d %s", ErrInsufficientFunds, from.Name, from.Coins[coinType], coinType)
+		}
+	}
+	value := CoinValue(money)
+	if kind == RepaymentTransfer {
+		if owed := Owed(p, toID, fromID); value > owed {
+			return models.Transaction{}, fmt.Errorf("%s only owes %s %d %s worth", from.Name, to.Name, owed, baseCoinName())
+		}
+	}
+
+	if to.Coins == nil {
+		to.Coins = make(map[string]int)
+	}
+	sent := make(map[string]int)
+	for _, coinType := range models.CoinOrder {
+		if amount := money[coinType]; amount > 0 {
+			from.Coins[coinType] -= amount
+			to.Coins[coinType] += amount
+			sent[coinType] = -amount
+		}
+	}
+	switch kind {
+	case LoanTransfer:
+		addDebt(p, fromID, toID, value)
+	case RepaymentTransfer:
+		addDebt(p, toID, fromID, -value)
+	}
+	log.Printf("%s gave %s %d copper worth of coins\n", from.Name, to.Name, value)
+
+	tx := models.NewTransaction(transferTypes[kind], "")
+	tx.Entries = append(tx.Entries,
+		models.LedgerEntry{MemberID: from.ID, Member: from.Name, Coins: sent},
+		models.LedgerEntry{MemberID: to.ID, Member: to.Name, Coins: maps.Clone(money)})
+	return tx, nil
+}
+
+// Owed returns how much the borrower owes the lender, in the currency's smallest coin
+func Owed(p *models.Party, lenderID string, borrowerID string) int {
+	for _, d := range p.Debts {
+		if d.LenderID == lenderID && d.BorrowerID == borrowerID {
+			return d.Amount
+		}
+	}
+	return 0
+}
+
+// Adds copper to what the borrower owes the lender. A new loan first cancels out anything
+// the lender owes the borrower, and debts that reach zero are dropped.
+func addDebt(p *models.Party, lenderID string, borrowerID string, copper int) {
+	if copper > 0 {
+		if reverse := Owed(p, borrowerID, lenderID); reverse > 0 {
+			cancelled := min(reverse, copper)
+			addDebt(p, borrowerID, lenderID, -cancelled)
+			copper -= cancelled
+		}
+	}
+
+	i := slices.IndexFunc(p.Debts, func(d models.Debt) bool { return d.LenderID == lenderID && d.BorrowerID == borrowerID })
+	if i < 0 {
+		if copper > 0 {
+			p.Debts = append(p.Debts, models.Debt{LenderID: lenderID, BorrowerID: borrowerID, Amount: copper})
+		}
+		return
+	}
+	p.Debts[i].Amount += copper
+	if p.Debts[i].Amount <= 0 {
+		p.Debts = slices.Delete(p.Debts, i, i+1)
+	}
+}
+
+// Drops every debt owed to or by a member
+func forgetDebts(p *models.Party, id string) {
+	p.Debts = slices.DeleteFunc(p.Debts, func(d models.Debt) bool { return d.LenderID == id || d.BorrowerID == id })
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"testing"
+)
+
+func TestTransferCoins(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", Coins: map[string]int{models.Gold: 10}},
+			{ID: "rowan", Name: "Rowan", Coins: map[string]int{models.Silver: 50}},
+		},
+	}
+
+	tx, err := TransferCoins(&party, "keg", "rowan", map[string]int{models.Gold: 2}, GiftTransfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Type != models.Transfer || tx.Entries[0].Coins[models.Gold] != -2 || tx.Entries[1].Coins[models.Gold] != 2 {
+		t.Errorf("Unexpected ledger for a gift: %+v", tx)
+	}
+	if len(party.Debts) != 0 {
+		t.Errorf("Expected a gift not to create a debt, got %+v", party.Debts)
+	}
+
+	// Rowan borrows 5 gold, then pays back 3 in silver
+	if _, err := TransferCoins(&party, "keg", "rowan", map[string]int{models.Gold: 5}, LoanTransfer); err != nil {
+		t.Fatal(err)
+	}
+	if owed := Owed(&party, "keg", "rowan"); owed != 500 {
+		t.Errorf("Expected Rowan to owe 500 copper, got %d", owed)
+	}
+	if _, err := TransferCoins(&party, "rowan", "keg", map[string]int{models.Silver: 30}, RepaymentTransfer); err != nil {
+		t.Fatal(err)
+	}
+	if owed := Owed(&party, "keg", "rowan"); owed != 200 {
+		t.Errorf("Expected Rowan to owe 200 copper after repaying, got %d", owed)
+	}
+	if _, err := TransferCoins(&party, "rowan", "keg", map[string]int{models.Gold: 3}, RepaymentTransfer); err == nil {
+		t.Error("Expected repaying more than is owed to fail")
+	}
+
+	// Keg borrowing back more than Rowan owes cancels Rowan's debt first
+	if _, err := TransferCoins(&party, "rowan", "keg", map[string]int{models.Gold: 4}, LoanTransfer); err != nil {
+		t.Fatal(err)
+	}
+	if Owed(&party, "keg", "rowan") != 0 || Owed(&party, "rowan", "keg") != 200 {
+		t.Errorf("Expected the loans to net out to Keg owing 200 copper, got %+v", party.Debts)
+	}
+
+	if _, err := TransferCoins(&party, "keg", "rowan", map[string]int{models.Platinum: 1}, GiftTransfer); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected giving coins Keg doesn't have to fail, got %v", err)
+	}
+	if _, err := TransferCoins(&party, "keg", "keg", map[string]int{models.Gold: 1}, GiftTransfer); err == nil {
+		t.Error("Expected a transfer to oneself to fail")
+	}
+	if _, err := TransferCoins(&party, "keg", "nobody", map[string]int{models.Gold: 1}, GiftTransfer); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Expected a transfer to a missing member to fail, got %v", err)
+	}
+}
+
+func TestSettleDebts(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+		},
+		Debts: []models.Debt{{LenderID: "keg", BorrowerID: "rowan", Amount: 250}},
+	}
+
+	// Rowan's 5 gold pays off the 2.5 gold owed, with a gold broken into silver
+	plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 10}, CoinOptions{SettleDebts: true})
+	if len(plan.Settlements) != 1 || CoinValue(plan.Settlements[0].Coins) != 250 {
+		t.Fatalf("Expected Rowan to pay back 250 copper, got %+v", plan.Settlements)
+	}
+	ApplyCoinPlan(&party, plan)
+
+	keg, rowan := party.ActiveMembers[0], party.ActiveMembers[1]
+	if CoinValue(keg.Coins) != 750 || CoinValue(rowan.Coins) != 250 {
+		t.Errorf("Expected Keg to end up with 750 copper worth and Rowan 250, got %v and %v", keg.Coins, rowan.Coins)
+	}
+	if len(party.Debts) != 0 {
+		t.Errorf("Expected the debt to be settled, got %+v", party.Debts)
+	}
+
+	// Without the option nothing is taken
+	party.Debts = []models.Debt{{LenderID: "keg", BorrowerID: "rowan", Amount: 100}}
+	if plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 2}, CoinOptions{}); len(plan.Settlements) != 0 {
+		t.Errorf("Expected no settlements, got %+v", plan.Settlements)
+	}
+
+	// Removing a member forgets their debts
+	if _, err := DeleteMember(&party, "rowan", DiscardWallet); err != nil {
+		t.Fatal(err)
+	}
+	if len(party.Debts) != 0 {
+		t.Errorf("Expected Rowan's debts to be dropped, got %+v", party.Debts)
+	}
+}
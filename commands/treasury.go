@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+)
+
+// ErrInsufficientFunds is returned when there aren't enough coins to cover a payment
+var ErrInsufficientFunds = errors.New("not enough coins")
+
+// Deposit adds coins straight to the party treasury
+func Deposit(p *models.Party, money map[string]int) models.Transaction {
+	addToTreasury(p, money)
+	log.Println("Coins added to the treasury")
+
+	tx := models.NewTransaction(models.Deposit, "")
+	tx.Entries = append(tx.Entries, models.LedgerEntry{Member: models.TreasuryName, Coins: maps.Clone(money)})
+	return tx
+}
+
+// Withdraw moves coins from the party treasury to the wallet of the active or inactive
+// member with the given ID. Nothing changes unless the treasury can cover every coin.
+func Withdraw(p *models.Party, id string, money map[string]int) (models.Transaction, error) {
+	member := findAnyMember(p, id)
+	if member == nil {
+		return models.Transaction{}, fmt.Errorf("%w: no member with ID %s", ErrMemberNotFound, id)
+	}
+	for _, coinType := range models.CoinOrder {
+		if money[coinType] < 0 {
+			return models.Transaction{}, fmt.Errorf("%s can't be negative", coinType)
+		}
+		if money[coinType] > p.Treasury[coinType] {
+			return models.Transaction{}, fmt.Errorf("%w: the treasury has %d %s", ErrInsufficientFunds, p.Treasury[coinType], coinType)
+		}
+	}
+
+	if member.Coins == nil {
+		member.Coins = make(map[string]int)
+	}
+	taken := make(map[string]int)
+	for _, coinType := range models.CoinOrder {
+		if amount := money[coinType]; amount > 0 {
+			p.Treasury[coinType] -= amount
+			member.Coins[coinType] += amount
+			taken[coinType] = -amount
+		}
+	}
+	log.Printf("%s took coins from the treasury\n", member.Name)
+
+	tx := models.NewTransaction(models.Withdrawal, "")
+	tx.Entries = append(tx.Entries,
+		models.LedgerEntry{Member: models.TreasuryName, Coins: taken},
+		models.LedgerEntry{MemberID: member.ID, Member: member.Name, Coins: maps.Clone(money)})
+	return tx, nil
+}
+
+// Returns the part of money set aside for the treasury, rounding each coin type down
+func treasuryCut(money map[string]int, percent int) map[string]int {
+	cut := make(map[string]int)
+	for coinType, amount := range money {
+		if c := amount * percent / 100; c > 0 {
+			cut[coinType] = c
+		}
+	}
+	return cut
+}
+
+func addToTreasury(p *models.Party, money map[string]int) {
+	if p.Treasury == nil {
+		p.Treasury = make(map[string]int)
+	}
+	for coinType, amount := range money {
+		p.Treasury[coinType] += amount
+	}
+}
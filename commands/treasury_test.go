@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"testing"
+)
+
+func TestTreasury(t *testing.T) {
+	party := models.Party{
+		ActiveMembers: []models.Member{
+			{ID: "keg", Name: "Keg", CoinPriority: 0, Coins: make(map[string]int)},
+			{ID: "rowan", Name: "Rowan", CoinPriority: 1, Coins: make(map[string]int)},
+		},
+	}
+
+	// A quarter of 10 gold rounds down to 2 for the treasury, leaving 8 to split
+	plan := PlanCoinDistribution(&party, map[string]int{models.Gold: 10}, CoinOptions{TreasuryPercent: 25})
+	tx := ApplyCoinPlan(&party, plan)
+	if party.Treasury[models.Gold] != 2 || party.ActiveMembers[0].Coins[models.Gold] != 4 {
+		t.Errorf("Expected 2 gold in the treasury and 4 each, got %v and %v", party.Treasury, party.ActiveMembers[0].Coins)
+	}
+	if tx.Entries[0].Member != models.TreasuryName || tx.Entries[0].Coins[models.Gold] != 2 {
+		t.Errorf("Expected the ledger to record the treasury's cut first, got %+v", tx.Entries)
+	}
+
+	Deposit(&party, map[string]int{models.Silver: 30})
+	if _, err := Withdraw(&party, "rowan", map[string]int{models.Gold: 3}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected overdrawing the treasury to fail, got %v", err)
+	}
+	if _, err := Withdraw(&party, "rowan", map[string]int{models.Gold: 2, models.Silver: 10}); err != nil {
+		t.Fatal(err)
+	}
+	rowan := party.ActiveMembers[1]
+	if rowan.Coins[models.Gold] != 6 || rowan.Coins[models.Silver] != 10 {
+		t.Errorf("Unexpected wallet after withdrawing: %v", rowan.Coins)
+	}
+	if party.Treasury[models.Gold] != 0 || party.Treasury[models.Silver] != 20 {
+		t.Errorf("Unexpected treasury after withdrawing: %v", party.Treasury)
+	}
+}
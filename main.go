@@ -1,7 +1,10 @@
 package main
 
 import (
+	"dndgoldtracker/cli"
+	"dndgoldtracker/storage"
 	"dndgoldtracker/ui"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +13,10 @@ import (
 )
 
 func main() {
+	campaign := flag.String("campaign", "", "campaign to load and save")
+	flag.Usage = func() { cli.PrintUsage(os.Stderr) }
+	flag.Parse()
+
 	fileName := "logFile.log"
 
 	// open log file
@@ -25,6 +32,24 @@ func main() {
 	// optional: log date-time, filename, and line number
 	log.SetFlags(log.Lshortfile | log.LstdFlags)
 
+	// Open the requested campaign, or the one used last time. A campaign that was used last
+	// time may have been deleted or renamed since, so fall back to the default one then
+	if *campaign != "" {
+		err = storage.UseCampaign(*campaign)
+	} else if err = storage.UseCampaign(storage.LastCampaign()); err != nil {
+		log.Printf("Couldn't open the last used campaign, opening %q instead: %v", storage.DefaultCampaign, err)
+		err = storage.UseCampaign(storage.DefaultCampaign)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	// Run a single command when one is given instead of the interactive program
+	if flag.NArg() > 0 {
+		os.Exit(cli.Run(flag.Args(), os.Stdout, os.Stderr))
+	}
+
 	// Initialize and run the program
 	p := tea.NewProgram(ui.NewModel())
 
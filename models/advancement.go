@@ -0,0 +1,10 @@
+package models
+
+// The levels at which most 5e classes get an Ability Score Improvement
+var ASILevels = []int{4, 8, 12, 16, 19}
+
+// ProficiencyBonus returns a 5e character's proficiency bonus at the given level,
+// which stays at +6 past level 20
+func ProficiencyBonus(level int) int {
+	return 2 + (min(max(level, 1), 20)-1)/4
+}
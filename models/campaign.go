@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// How the characters in a campaign gain levels
+const (
+	XPLeveling        = ""          // levels follow each member's XP total
+	MilestoneLeveling = "milestone" // the DM levels characters directly when the party reaches a milestone
+)
+
+// Campaign holds the settings for one saved campaign.
+// The name is the campaign's directory name and isn't stored in the file.
+type Campaign struct {
+	Name     string `json:"-"`
+	Archived bool
+	Created  time.Time
+	Leveling string    `json:",omitempty"`
+	XPTable  *XPTable  `json:",omitempty"` // the 5e table is used if this isn't set
+	Currency *Currency `json:",omitempty"` // the 5e coins are used if this isn't set
+}
+
+// The leveling of the campaign being played, set whenever a campaign is loaded
+var Leveling = XPLeveling
+
+// UsesMilestones reports whether the campaign being played levels characters by milestone
+func UsesMilestones() bool {
+	return Leveling == MilestoneLeveling
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+const (
+	// Coin types of the 5e currency
+	Platinum string = "Platinum"
+	Gold     string = "Gold"
+	Electrum string = "Electrum"
+	Silver   string = "Silver"
+	Copper   string = "Copper"
+)
+
+// Coin is one denomination of a currency
+type Coin struct {
+	Name     string // also the key the coins are stored under in wallets and the ledger
+	Abbr     string // short form used for command line flags and prices, e.g. "gp"
+	Value    int    // what the coin is worth in the currency's smallest coin
+	NoChange bool   `json:",omitempty"` // merchants don't pay or give change in it, like electrum
+}
+
+// Currency is the set of coins a campaign uses
+type Currency struct {
+	Name     string
+	Coins    []Coin // from most to least valuable, ending with the coin everything is counted in
+	Standard string // the coin item values are given in
+}
+
+// The built in currencies, the first being the default
+var Currencies = []Currency{
+	{
+		Name: "5e",
+		Coins: []Coin{
+			{Name: Platinum, Abbr: "pp", Value: 1000},
+			{Name: Gold, Abbr: "gp", Value: 100},
+			{Name: Electrum, Abbr: "ep", Value: 50, NoChange: true},
+			{Name: Silver, Abbr: "sp", Value: 10},
+			{Name: Copper, Abbr: "cp", Value: 1},
+		},
+		Standard: Gold,
+	},
+	{
+		Name: "gsc",
+		Coins: []Coin{
+			{Name: Gold, Abbr: "gp", Value: 100},
+			{Name: Silver, Abbr: "sp", Value: 10},
+			{Name: Copper, Abbr: "cp", Value: 1},
+		},
+		Standard: Gold,
+	},
+}
+
+var (
+	// The currency of the campaign being played, set whenever a campaign is loaded
+	CurrentCurrency = Currencies[0]
+
+	// The names of the current currency's coins, most valuable first
+	CoinOrder = CurrentCurrency.names()
+
+	// What each of the current currency's coins is worth in its smallest coin
+	CoinValues = CurrentCurrency.values()
+)
+
+// UseCurrency makes c the currency every coin is counted in
+func UseCurrency(c Currency) {
+	CurrentCurrency = c
+	CoinOrder = c.names()
+	CoinValues = c.values()
+}
+
+// FindCurrency returns the built in currency with the given name
+func FindCurrency(name string) (Currency, bool) {
+	i := slices.IndexFunc(Currencies, func(c Currency) bool { return c.Name == name })
+	if i < 0 {
+		return Currency{}, false
+	}
+	return Currencies[i], true
+}
+
+// Validate checks that every coin has a unique name and abbreviation, that the coins go from most
+// to least valuable ending with one worth 1, and that each coin breaks evenly into smaller change
+func (c Currency) Validate() error {
+	if c.Name == "" {
+		return errors.New("a currency needs a name")
+	}
+	if len(c.Coins) == 0 {
+		return fmt.Errorf("currency %s has no coins", c.Name)
+	}
+	names := make(map[string]bool)
+	abbrs := make(map[string]bool)
+	for i, coin := range c.Coins {
+		if coin.Name == "" || coin.Abbr == "" {
+			return fmt.Errorf("currency %s: every coin needs a name and an abbreviation", c.Name)
+		}
+		if names[coin.Name] || abbrs[coin.Abbr] {
+			return fmt.Errorf("currency %s: %s (%s) is listed twice", c.Name, coin.Name, coin.Abbr)
+		}
+		names[coin.Name], abbrs[coin.Abbr] = true, true
+		for _, r := range coin.Abbr {
+			if r < 'a' || r > 'z' {
+				return fmt.Errorf("currency %s: abbreviation %q must be lowercase letters", c.Name, coin.Abbr)
+			}
+		}
+		if i > 0 && coin.Value >= c.Coins[i-1].Value {
+			return fmt.Errorf("currency %s: %s must be worth less than %s", c.Name, coin.Name, c.Coins[i-1].Name)
+		}
+	}
+	if base := c.Base(); base.Value != 1 || base.NoChange {
+		return fmt.Errorf("currency %s: the last coin, %s, must be worth 1 and given as change", c.Name, base.Name)
+	}
+	for _, coin := range c.Coins {
+		if change, ok := c.ChangeFor(coin.Name); ok && coin.Value%change.Value != 0 {
+			return fmt.Errorf("currency %s: %s doesn't break evenly into %s", c.Name, coin.Name, change.Name)
+		}
+	}
+	if !names[c.Standard] {
+		return fmt.Errorf("currency %s: the standard coin %q isn't one of its coins", c.Name, c.Standard)
+	}
+	return nil
+}
+
+// Base returns the least valuable coin, which values are counted in
+func (c Currency) Base() Coin {
+	return c.Coins[len(c.Coins)-1]
+}
+
+// StandardCoin returns the coin item values are given in
+func (c Currency) StandardCoin() Coin {
+	return c.Coins[slices.IndexFunc(c.Coins, func(coin Coin) bool { return coin.Name == c.Standard })]
+}
+
+// ChangeCoins returns the names of the coins merchants pay and give change in, most valuable first
+func (c Currency) ChangeCoins() []string {
+	var coins []string
+	for _, coin := range c.Coins {
+		if !coin.NoChange {
+			coins = append(coins, coin.Name)
+		}
+	}
+	return coins
+}
+
+// ChangeFor returns the coin a coin is broken into when change is needed,
+// the next smaller one given as change. Returns false for the smallest coin.
+func (c Currency) ChangeFor(name string) (Coin, bool) {
+	i := slices.IndexFunc(c.Coins, func(coin Coin) bool { return coin.Name == name })
+	if i < 0 {
+		return Coin{}, false
+	}
+	for _, coin := range c.Coins[i+1:] {
+		if !coin.NoChange {
+			return coin, true
+		}
+	}
+	return Coin{}, false
+}
+
+// Format writes a value counted in the smallest coin in standard coins, e.g. "12.5 gp"
+func (c Currency) Format(value int) string {
+	standard := c.StandardCoin()
+	return strconv.FormatFloat(float64(value)/float64(standard.Value), 'f', -1, 64) + " " + standard.Abbr
+}
+
+func (c Currency) names() []string {
+	names := make([]string, len(c.Coins))
+	for i, coin := range c.Coins {
+		names[i] = coin.Name
+	}
+	return names
+}
+
+func (c Currency) values() map[string]int {
+	values := make(map[string]int)
+	for _, coin := range c.Coins {
+		values[coin.Name] = coin.Value
+	}
+	return values
+}
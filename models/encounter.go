@@ -0,0 +1,46 @@
+package models
+
+var (
+	// XP for defeating a monster of each challenge rating, taken from the D&D 5e Monster Manual
+	CRExperience = map[string]int{
+		"0": 10, "1/8": 25, "1/4": 50, "1/2": 100,
+		"1": 200, "2": 450, "3": 700, "4": 1100, "5": 1800,
+		"6": 2300, "7": 2900, "8": 3900, "9": 5000, "10": 5900,
+		"11": 7200, "12": 8400, "13": 10000, "14": 11500, "15": 13000,
+		"16": 15000, "17": 18000, "18": 20000, "19": 22000, "20": 25000,
+		"21": 33000, "22": 41000, "23": 50000, "24": 62000, "25": 75000,
+		"26": 90000, "27": 105000, "28": 120000, "29": 135000, "30": 155000,
+	}
+
+	// Encounter difficulties, from easiest to hardest
+	Difficulties = []string{"Easy", "Medium", "Hard", "Deadly"}
+
+	// XP thresholds for each difficulty by character level, taken from the D&D 5e Dungeon Master's Guide.
+	// Index 0 is level 1.
+	DifficultyThresholds = [][]int{
+		{25, 50, 75, 100},
+		{50, 100, 150, 200},
+		{75, 150, 225, 400},
+		{125, 250, 375, 500},
+		{250, 500, 750, 1100},
+		{300, 600, 900, 1400},
+		{350, 750, 1100, 1700},
+		{450, 900, 1400, 2100},
+		{550, 1100, 1600, 2400},
+		{600, 1200, 1900, 2800},
+		{800, 1600, 2400, 3600},
+		{1000, 2000, 3000, 4500},
+		{1100, 2200, 3400, 5100},
+		{1250, 2500, 3800, 5700},
+		{1400, 2800, 4300, 6400},
+		{1600, 3200, 4800, 7200},
+		{2000, 3900, 5900, 8800},
+		{2100, 4200, 6300, 9500},
+		{2400, 4900, 7300, 10900},
+		{2800, 5700, 8500, 12700},
+	}
+
+	// The DMG's encounter multipliers, from a lone monster faced by a large party up to
+	// fifteen or more monsters faced by a small one
+	EncounterMultipliers = []float64{0.5, 1, 1.5, 2, 2.5, 3, 4, 5}
+)
@@ -0,0 +1,14 @@
+package models
+
+// Snapshot is a saved copy of the party taken just before an action changed it
+type Snapshot struct {
+	Action string
+	Party  Party
+}
+
+// History holds the snapshots used to undo and redo party changes.
+// The most recent snapshot is at the end of each stack.
+type History struct {
+	Undo []Snapshot
+	Redo []Snapshot
+}
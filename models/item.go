@@ -0,0 +1,20 @@
+package models
+
+// The most magic items a character can be attuned to at once in 5e
+const MaxAttuned = 3
+
+// Item is a stack of identical loot: a gem, an art object, a magic item or mundane gear
+type Item struct {
+	ID       string
+	Name     string
+	Quantity int
+	ValueGP  int     // what one of the items is worth, in the currency's standard coin (gold pieces in 5e)
+	Weight   float64 // the weight of one of the items, in pounds
+	Attuned  bool
+	Notes    string `json:",omitempty"`
+}
+
+// Value returns what the whole stack is worth in the currency's standard coin
+func (i Item) Value() int {
+	return i.ValueGP * i.Quantity
+}
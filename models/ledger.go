@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+const (
+	// Transaction types
+	CoinAward     string = "CoinAward"
+	XPAward       string = "XPAward"
+	MemberAdded   string = "MemberAdded"
+	GroupChange   string = "GroupChange"
+	MemberEdited  string = "MemberEdited"
+	MemberRemoved string = "MemberRemoved"
+	Deposit       string = "Deposit"
+	Withdrawal    string = "Withdrawal"
+	Purchase      string = "Purchase"
+	Transfer      string = "Transfer"
+	Loan          string = "Loan"
+	Repayment     string = "Repayment"
+	LootAdded     string = "LootAdded"
+	ItemAssigned  string = "ItemAssigned"
+	ItemReturned  string = "ItemReturned"
+	ItemAttuned   string = "ItemAttuned"
+	ItemSold      string = "ItemSold"
+	LevelChanged  string = "LevelChanged"
+	Undo          string = "Undo"
+	Redo          string = "Redo"
+
+	// Member groups
+	ActiveGroup   string = "Active"
+	InactiveGroup string = "Inactive"
+
+	// The names ledger entries for the party treasury and loot pool are recorded under
+	TreasuryName string = "Treasury"
+	LootName     string = "Loot"
+)
+
+// LedgerEntry records how a single member, the treasury or the loot pool was affected by a transaction.
+// Treasury and loot pool entries have no member ID.
+type LedgerEntry struct {
+	MemberID string
+	Member   string
+	Coins    map[string]int `json:",omitempty"`
+	XP       int            `json:",omitempty"`
+	Group    string         `json:",omitempty"`
+	Item     string         `json:",omitempty"`
+	Quantity int            `json:",omitempty"` // how many of the item were gained or lost
+	Level    int            `json:",omitempty"` // the level reached at a milestone
+}
+
+// Transaction is a single record in the party ledger
+type Transaction struct {
+	Type      string
+	Timestamp time.Time
+	Reason    string `json:",omitempty"`
+	Entries   []LedgerEntry
+}
+
+// NewTransaction creates an empty transaction of the given type stamped with the current time
+func NewTransaction(txType string, reason string) Transaction {
+	return Transaction{Type: txType, Timestamp: time.Now(), Reason: reason}
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Milestone is a level a member reached under milestone leveling
+type Milestone struct {
+	Timestamp time.Time
+	Level     int
+	Session   int    `json:",omitempty"` // the game session it happened in, 0 if it wasn't given
+	Reason    string `json:",omitempty"`
+}
@@ -1,42 +1,82 @@
 package models
 
-import "fmt"
-
-const (
-	// Coin types
-	Platinum string = "Platinum"
-	Gold     string = "Gold"
-	Electrum string = "Electrum"
-	Silver   string = "Silver"
-	Copper   string = "Copper"
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 )
 
-var (
-	// Define the fixed order of coins
-	CoinOrder    = []string{Platinum, Gold, Electrum, Silver, Copper}
-	XpThresholds = []int{0, 300, 900, 2700, 6500, 14000, 23000, 34000, 48000, 64000, 85000, 100000, 120000, 140000, 165000, 195000, 225000, 265000, 305000, 355000} // XP values taken for D&D 5e
+const (
+	// The share weight of a member who gets an ordinary cut of coins and XP
+	FullShare = 1.0
+	// The smallest share weight, as shares are split in hundredths
+	MinShare = 0.01
 )
 
 type Member struct {
+	ID           string
 	Name         string
 	Level        int
 	XP           int
 	Coins        map[string]int
 	CoinPriority int
+	Share        float64     // how big a cut of coins and XP the member gets, e.g. 0.5 for a hireling
+	Items        []Item      `json:",omitempty"`
+	Milestones   []Milestone `json:",omitempty"` // every level reached under milestone leveling, oldest first
 }
 
 type Party struct {
 	ActiveMembers   []Member
 	InactiveMembers []Member
+	Treasury        map[string]int // coins that belong to the whole party rather than any one member
+	Debts           []Debt
+	Loot            []Item `json:",omitempty"` // items the party has found that nobody has claimed yet
+	Sales           []Sale `json:",omitempty"`
+}
+
+// Debt is what one member still owes another for a loan, kept in the currency's smallest coin
+// (copper pieces in 5e)
+type Debt struct {
+	LenderID   string
+	BorrowerID string
+	Amount     int `json:"Copper"` // saved under the name it had when every campaign used 5e coins
 }
 
 // Display prints the current party state
 func (p *Party) Display() {
 	fmt.Println("\n=== Party Members ===")
 	for _, member := range p.ActiveMembers {
-		fmt.Printf("%s (Level %d) - XP: %d, Wallet:%dPP %dGP %dEP %dSP %dCP \n",
-			member.Name, member.Level, member.XP,
-			member.Coins[Platinum], member.Coins[Gold], member.Coins[Electrum],
-			member.Coins[Silver], member.Coins[Copper])
+		fmt.Printf("%s (Level %d) - XP: %d, Wallet:", member.Name, member.Level, member.XP)
+		for _, c := range CurrentCurrency.Coins {
+			fmt.Printf("%d%s ", member.Coins[c.Name], strings.ToUpper(c.Abbr))
+		}
+		fmt.Println()
+	}
+}
+
+// Clone returns a deep copy of the party so it can be changed without affecting the original
+func (p Party) Clone() Party {
+	return Party{
+		ActiveMembers:   cloneMembers(p.ActiveMembers),
+		InactiveMembers: cloneMembers(p.InactiveMembers),
+		Treasury:        maps.Clone(p.Treasury),
+		Debts:           slices.Clone(p.Debts),
+		Loot:            slices.Clone(p.Loot),
+		Sales:           slices.Clone(p.Sales), // sales are never changed once recorded
+	}
+}
+
+func cloneMembers(members []Member) []Member {
+	if members == nil {
+		return nil
+	}
+	c := make([]Member, len(members))
+	for i, m := range members {
+		c[i] = m
+		c[i].Coins = maps.Clone(m.Coins)
+		c[i].Items = slices.Clone(m.Items)
+		c[i].Milestones = slices.Clone(m.Milestones)
 	}
+	return c
 }
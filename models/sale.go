@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Sale records an item sold to a merchant and where the money went
+type Sale struct {
+	Timestamp time.Time
+	Item      string
+	Quantity  int
+	ValueGP   int // the listed value of one of the items, in the standard coin
+	Percent   int // how much of the listed value the merchant paid
+	Coins     map[string]int
+	SellerID  string // empty when the item came from the loot pool
+	Seller    string
+	Kept      bool // the seller kept the money instead of splitting it among the active members
+}
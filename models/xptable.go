@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// XPTable is a progression of the total XP needed to reach each level
+type XPTable struct {
+	Name       string
+	Thresholds []int // index 0 is level 1, which always needs 0 XP
+}
+
+// The built in XP tables, the first being the default
+var XPTables = []XPTable{
+	{Name: "5e", Thresholds: []int{0, 300, 900, 2700, 6500, 14000, 23000, 34000, 48000, 64000, 85000, 100000, 120000, 140000, 165000, 195000, 225000, 265000, 305000, 355000}},
+	{Name: "pf1-slow", Thresholds: []int{0, 3000, 7500, 14000, 23000, 35000, 53000, 77000, 115000, 160000, 235000, 330000, 475000, 665000, 955000, 1350000, 1900000, 2700000, 3850000, 5350000}},
+	{Name: "pf1-medium", Thresholds: []int{0, 2000, 5000, 9000, 15000, 23000, 35000, 51000, 75000, 105000, 155000, 220000, 315000, 445000, 635000, 890000, 1300000, 1800000, 2550000, 3600000}},
+	{Name: "pf1-fast", Thresholds: []int{0, 1300, 3300, 6000, 10000, 15000, 23000, 34000, 50000, 71000, 105000, 145000, 210000, 295000, 425000, 600000, 850000, 1200000, 1700000, 2400000}},
+	// 5e carried on to level 30, each level needing 5000 XP more than the last
+	{Name: "homebrew-30", Thresholds: []int{0, 300, 900, 2700, 6500, 14000, 23000, 34000, 48000, 64000, 85000, 100000, 120000, 140000, 165000, 195000, 225000, 265000, 305000, 355000,
+		405000, 460000, 520000, 585000, 655000, 730000, 810000, 895000, 985000, 1080000}},
+}
+
+// The XP table of the campaign being played, set whenever a campaign is loaded
+var CurrentXPTable = XPTables[0]
+
+// FindXPTable returns the built in XP table with the given name
+func FindXPTable(name string) (XPTable, bool) {
+	for _, t := range XPTables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return XPTable{}, false
+}
+
+// Validate checks that the table starts at 0 XP and that every level needs more XP than the one before
+func (t XPTable) Validate() error {
+	if t.Name == "" {
+		return errors.New("an XP table needs a name")
+	}
+	if len(t.Thresholds) == 0 {
+		return fmt.Errorf("XP table %s has no levels", t.Name)
+	}
+	if t.Thresholds[0] != 0 {
+		return fmt.Errorf("XP table %s must start level 1 at 0 XP", t.Name)
+	}
+	for i := 1; i < len(t.Thresholds); i++ {
+		if t.Thresholds[i] <= t.Thresholds[i-1] {
+			return fmt.Errorf("XP table %s: level %d needs %d XP, which isn't more than level %d", t.Name, i+1, t.Thresholds[i], i)
+		}
+	}
+	return nil
+}
+
+// FifthEdition reports whether the table is 5e's own or carries it on, so 5e's class features apply
+func (t XPTable) FifthEdition() bool {
+	return t.Name == "5e" || t.Name == "homebrew-30"
+}
+
+// MaxLevel returns the highest level in the table
+func (t XPTable) MaxLevel() int {
+	return len(t.Thresholds)
+}
+
+// Level returns the level reached with the given amount of XP
+func (t XPTable) Level(xp int) int {
+	level := 1
+	for level < t.MaxLevel() && xp >= t.Thresholds[level] {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	backupsDir   = "backups"
+	backupPrefix = "party-"
+	backupLayout = "20060102-150405.000000000"
+
+	// The number of backups kept for each campaign
+	maxBackups = 10
+)
+
+// Backup is a saved copy of a campaign's party file
+type Backup struct {
+	Path    string
+	Created time.Time
+}
+
+// ListBackups returns the current campaign's party backups, newest first
+func ListBackups() ([]Backup, error) {
+	dir, err := campaignPath(backupsDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(strings.TrimSuffix(e.Name(), ".json"), backupPrefix)
+		if !ok {
+			continue
+		}
+		created, err := time.ParseInLocation(backupLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, e.Name()), Created: created})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return b.Created.Compare(a.Created) })
+	return backups, nil
+}
+
+// LatestValidBackup returns the newest backup that can still be loaded, along with its party
+func LatestValidBackup() (Backup, models.Party, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return Backup{}, models.Party{}, err
+	}
+	for _, b := range backups {
+		if party, err := loadPartyFile(b.Path); err == nil {
+			return b, party, nil
+		}
+	}
+	return Backup{}, models.Party{}, errors.New("no valid backups found")
+}
+
+// RestoreBackup replaces the current campaign's party file with a backup.
+// A valid party file is backed up first and a damaged one is set aside.
+// A party file written by a newer version is never restored over.
+func RestoreBackup(b Backup) error {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+	path, err := campaignPath(partyFile)
+	if err != nil {
+		return err
+	}
+	if _, err := loadPartyFile(path); errors.Is(err, ErrNewerVersion) {
+		return fmt.Errorf("not restoring over the party file: %w", err)
+	}
+	if err := backupParty(path); err != nil {
+		return err
+	}
+	if _, err := SetAsideParty(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// SetAsideParty renames a damaged party file so it's kept for inspection but no
+// longer loaded. Returns the new name, or "" if there was no damaged party file.
+// A party file written by a newer version isn't damaged, so it's left alone with an error.
+func SetAsideParty() (string, error) {
+	path, err := campaignPath(partyFile)
+	if err != nil {
+		return "", err
+	}
+	_, err = loadPartyFile(path)
+	if errors.Is(err, ErrNewerVersion) {
+		return "", fmt.Errorf("not setting aside the party file: %w", err)
+	}
+	if !errors.Is(err, ErrCorrupt) {
+		return "", nil
+	}
+	aside := fmt.Sprintf("%s.damaged-%s", path, time.Now().Format(backupLayout))
+	return aside, os.Rename(path, aside)
+}
+
+// Copies the party file into the backups directory and removes the oldest
+// backups beyond maxBackups. Damaged files aren't backed up.
+func backupParty(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := loadPartyFile(path); err != nil {
+		return nil
+	}
+
+	dir, err := campaignPath(backupsDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := backupPrefix + time.Now().Format(backupLayout) + ".json"
+	if err := writeFileAtomic(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+
+	backups, err := ListBackups()
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(len(backups), maxBackups):] {
+		if err := os.Remove(b.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
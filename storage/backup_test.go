@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Gives each test its own empty data directory
+func useTempData(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if err := UseCampaign(DefaultCampaign); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSavePartyKeepsRollingBackups(t *testing.T) {
+	useTempData(t)
+
+	for i := range maxBackups + 3 {
+		party := models.Party{ActiveMembers: []models.Member{{Name: "Keg", XP: i}}}
+		if err := SaveParty(&party); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != maxBackups {
+		t.Fatalf("Expected %d backups, got %d", maxBackups, len(backups))
+	}
+
+	// The newest backup is the save before the last one
+	_, party, err := LatestValidBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xp := party.ActiveMembers[0].XP; xp != maxBackups+1 {
+		t.Errorf("Expected the latest backup to have %d XP, got %d", maxBackups+1, xp)
+	}
+
+	// No temporary files are left behind
+	dir, _ := campaignDir(DefaultCampaign)
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("Found leftover temporary files: %v", leftovers)
+	}
+}
+
+func TestRestoreDamagedParty(t *testing.T) {
+	useTempData(t)
+
+	for _, name := range []string{"Keg", "Rowan"} {
+		party := models.Party{ActiveMembers: []models.Member{{Name: name}}}
+		if err := SaveParty(&party); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Simulate a save that was cut off part way through
+	path, _ := campaignPath(partyFile)
+	if err := os.WriteFile(path, []byte(`{"ActiveMembers": [{"Na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadParty(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Expected ErrCorrupt, got %v", err)
+	}
+
+	backup, _, err := LatestValidBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBackup(backup); err != nil {
+		t.Fatal(err)
+	}
+
+	party, err := LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if party.ActiveMembers[0].Name != "Keg" {
+		t.Errorf("Expected Keg from the backup, got %s", party.ActiveMembers[0].Name)
+	}
+
+	// The damaged file is kept for inspection
+	damaged, _ := filepath.Glob(path + ".damaged-*")
+	if len(damaged) != 1 {
+		t.Errorf("Expected the damaged file to be set aside, found %v", damaged)
+	}
+}
+
+func TestNewerPartyIsNeverReplaced(t *testing.T) {
+	useTempData(t)
+
+	party := models.Party{ActiveMembers: []models.Member{{Name: "Keg"}}}
+	for range 2 {
+		if err := SaveParty(&party); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, _ := campaignPath(partyFile)
+	newer := []byte(`{"Version": 999, "Party": {"ActiveMembers": [{"Name": "Fred"}]}}`)
+	if err := os.WriteFile(path, newer, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SetAsideParty(); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected setting aside to refuse a newer file, got %v", err)
+	}
+	backup, _, err := LatestValidBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBackup(backup); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected restoring to refuse a newer file, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(newer) {
+		t.Errorf("Expected the newer file to be left alone, got %s", data)
+	}
+}
@@ -0,0 +1,375 @@
+package storage
+
+import (
+	"dndgoldtracker/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultCampaign = "default"
+
+	appDir       = "dndgoldtracker"
+	campaignsDir = "campaigns"
+	campaignFile = "campaign.json"
+	lastFile     = "last_campaign"
+)
+
+// The campaign every save is read from and written to
+var currentCampaign = DefaultCampaign
+
+// DataDir returns the per-user directory campaigns are stored under,
+// following the XDG base directory spec
+func DataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, appDir), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", appDir), nil
+}
+
+// CurrentCampaign returns the name of the campaign being saved to
+func CurrentCampaign() string {
+	return currentCampaign
+}
+
+// UseCampaign switches every save and load to the named campaign.
+// Only the default campaign is created if it doesn't exist yet, so a mistyped name
+// can't quietly start an empty campaign.
+func UseCampaign(name string) error {
+	if err := validateCampaignName(name); err != nil {
+		return err
+	}
+	dir, err := campaignDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if name != DefaultCampaign {
+			return fmt.Errorf("campaign %q doesn't exist, create it with \"campaign create\" or from the Campaigns screen", name)
+		}
+		if err := CreateCampaign(name); err != nil {
+			return err
+		}
+		if err := importLegacyFiles(dir); err != nil {
+			// Leave nothing behind so the import is tried again next time
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+	c, err := LoadCampaign(name)
+	if err != nil {
+		return err
+	}
+	currentCampaign = name
+	applySettings(c)
+	return nil
+}
+
+// Makes the rules the campaign being played follows match its settings
+func applySettings(c models.Campaign) {
+	models.Leveling = c.Leveling
+	models.CurrentXPTable = models.XPTables[0]
+	if c.XPTable != nil {
+		models.CurrentXPTable = *c.XPTable
+	}
+	currency := models.Currencies[0]
+	if c.Currency != nil {
+		currency = *c.Currency
+	}
+	models.UseCurrency(currency)
+}
+
+// LastCampaign returns the campaign that was last switched to, or the default campaign
+func LastCampaign() string {
+	dataDir, err := DataDir()
+	if err != nil {
+		return DefaultCampaign
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, lastFile))
+	name := strings.TrimSpace(string(data))
+	if err != nil || validateCampaignName(name) != nil {
+		return DefaultCampaign
+	}
+	return name
+}
+
+// SetLastCampaign remembers the campaign to open next time
+func SetLastCampaign(name string) error {
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dataDir, lastFile), []byte(name+"\n"), 0644)
+}
+
+// ListCampaigns returns every campaign, including archived ones, sorted by name
+func ListCampaigns() ([]models.Campaign, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dataDir, campaignsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var campaigns []models.Campaign
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		c, err := LoadCampaign(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, c)
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].Name < campaigns[j].Name })
+	return campaigns, nil
+}
+
+// LoadCampaign reads the settings for the named campaign
+func LoadCampaign(name string) (models.Campaign, error) {
+	dir, err := campaignDir(name)
+	if err != nil {
+		return models.Campaign{}, err
+	}
+	c := models.Campaign{Name: name}
+	data, err := os.ReadFile(filepath.Join(dir, campaignFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	if c.XPTable != nil {
+		if err := c.XPTable.Validate(); err != nil {
+			return c, fmt.Errorf("campaign %s: %w", name, err)
+		}
+	}
+	if c.Currency != nil {
+		if err := c.Currency.Validate(); err != nil {
+			return c, fmt.Errorf("campaign %s: %w", name, err)
+		}
+	}
+	return c, nil
+}
+
+// SaveCampaign writes the settings for a campaign
+func SaveCampaign(c models.Campaign) error {
+	dir, err := campaignDir(c.Name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, campaignFile), data, 0644); err != nil {
+		return err
+	}
+	if c.Name == currentCampaign {
+		applySettings(c)
+	}
+	return nil
+}
+
+// CreateCampaign makes a new, empty campaign
+func CreateCampaign(name string) error {
+	if err := validateCampaignName(name); err != nil {
+		return err
+	}
+	dir, err := campaignDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("campaign %q already exists", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return SaveCampaign(models.Campaign{Name: name, Created: time.Now()})
+}
+
+// RenameCampaign changes a campaign's name, following it if it's the current campaign
+func RenameCampaign(oldName string, newName string) error {
+	if err := validateCampaignName(oldName); err != nil {
+		return err
+	}
+	if err := validateCampaignName(newName); err != nil {
+		return err
+	}
+	oldDir, err := campaignDir(oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := campaignDir(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(oldDir); err != nil {
+		return fmt.Errorf("campaign %q doesn't exist", oldName)
+	}
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("campaign %q already exists", newName)
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return err
+	}
+
+	if currentCampaign == oldName {
+		currentCampaign = newName
+	}
+	if LastCampaign() == oldName {
+		return SetLastCampaign(newName)
+	}
+	return nil
+}
+
+// SetArchived archives or restores a campaign. Archived campaigns keep their saves
+// but are hidden from the campaign switcher.
+func SetArchived(name string, archived bool) error {
+	c, err := LoadCampaign(name)
+	if err != nil {
+		return err
+	}
+	c.Archived = archived
+	return SaveCampaign(c)
+}
+
+// SetLeveling switches how characters in a campaign gain levels,
+// either models.XPLeveling or models.MilestoneLeveling
+func SetLeveling(name string, leveling string) error {
+	if leveling != models.XPLeveling && leveling != models.MilestoneLeveling {
+		return fmt.Errorf("unknown leveling %q", leveling)
+	}
+	c, err := LoadCampaign(name)
+	if err != nil {
+		return err
+	}
+	c.Leveling = leveling
+	return SaveCampaign(c)
+}
+
+// SetXPTable changes the XP table a campaign's levels follow
+func SetXPTable(name string, table models.XPTable) error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+	c, err := LoadCampaign(name)
+	if err != nil {
+		return err
+	}
+	c.XPTable = &table
+	return SaveCampaign(c)
+}
+
+// ReadXPTable reads a custom XP table from a JSON file like
+// {"Name": "Gritty", "Thresholds": [0, 1000, 3000]}
+func ReadXPTable(path string) (models.XPTable, error) {
+	var table models.XPTable
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return table, err
+	}
+	if err := json.Unmarshal(data, &table); err != nil {
+		return table, fmt.Errorf("reading XP table %s: %w", path, err)
+	}
+	return table, table.Validate()
+}
+
+// SetCurrency changes the coins a campaign counts money in
+func SetCurrency(name string, currency models.Currency) error {
+	if err := currency.Validate(); err != nil {
+		return err
+	}
+	c, err := LoadCampaign(name)
+	if err != nil {
+		return err
+	}
+	c.Currency = &currency
+	return SaveCampaign(c)
+}
+
+// ReadCurrency reads a custom currency from a JSON file like {"Name": "Realm", "Standard": "Crown",
+// "Coins": [{"Name": "Crown", "Abbr": "cr", "Value": 60}, {"Name": "Penny", "Abbr": "d", "Value": 1}]}
+func ReadCurrency(path string) (models.Currency, error) {
+	var currency models.Currency
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return currency, err
+	}
+	if err := json.Unmarshal(data, &currency); err != nil {
+		return currency, fmt.Errorf("reading currency %s: %w", path, err)
+	}
+	return currency, currency.Validate()
+}
+
+// Returns the directory a campaign's saves live in
+func campaignDir(name string) (string, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, campaignsDir, name), nil
+}
+
+// Returns the path of a save file in the current campaign
+func campaignPath(file string) (string, error) {
+	dir, err := campaignDir(currentCampaign)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, file), nil
+}
+
+func validateCampaignName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("campaign name can't be empty")
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid campaign name %q", name)
+	}
+	return nil
+}
+
+// Copies saves from the working directory, where they lived before campaigns existed,
+// into a new campaign
+func importLegacyFiles(dir string) error {
+	for _, file := range []string{partyFile, historyFile, ledgerFile} {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = writeFileAtomic(filepath.Join(dir, file), data, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("importing %s into the %s campaign: %w", file, filepath.Base(dir), err)
+		}
+	}
+	return nil
+}
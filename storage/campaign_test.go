@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUseCampaignDoesNotCreate(t *testing.T) {
+	useTempData(t)
+
+	if err := UseCampaign("Curse of Strahd"); err == nil {
+		t.Error("Expected an unknown campaign to be refused")
+	}
+	if CurrentCampaign() != DefaultCampaign {
+		t.Errorf("Expected to stay in the default campaign, got %s", CurrentCampaign())
+	}
+	campaigns, err := ListCampaigns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns) != 1 {
+		t.Errorf("Expected only the default campaign, got %+v", campaigns)
+	}
+
+	if err := CreateCampaign("Curse of Strahd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseCampaign("Curse of Strahd"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRenameCampaignChecksOldName(t *testing.T) {
+	useTempData(t)
+
+	for _, oldName := range []string{"Missing", "../" + DefaultCampaign, ""} {
+		if err := RenameCampaign(oldName, "Barovia"); err == nil {
+			t.Errorf("Expected renaming %q to be refused", oldName)
+		}
+	}
+	if err := RenameCampaign(DefaultCampaign, "Barovia"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLegacyImportFailureIsReported(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// A directory in place of the old party file can't be read
+	if err := os.Mkdir(partyFile, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseCampaign(DefaultCampaign); err == nil {
+		t.Fatal("Expected the failed import to be reported")
+	}
+
+	// The import is tried again once the old save is readable
+	if err := os.Remove(partyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(partyFile, []byte(`{"ActiveMembers": [{"Name": "Keg"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseCampaign(DefaultCampaign); err != nil {
+		t.Fatal(err)
+	}
+	party, err := LoadParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.ActiveMembers) != 1 || party.ActiveMembers[0].Name != "Keg" {
+		t.Errorf("Expected Keg to be imported, got %+v", party.ActiveMembers)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Commit saves the party and its undo history and records the transactions in the ledger
+func Commit(party *models.Party, history *models.History, txs ...models.Transaction) error {
+	if err := SaveParty(party); err != nil {
+		return fmt.Errorf("saving party: %w", err)
+	}
+	if err := SaveHistory(history); err != nil {
+		return fmt.Errorf("saving undo history: %w", err)
+	}
+	for _, tx := range txs {
+		if err := AppendTransaction(tx); err != nil {
+			return fmt.Errorf("recording %s in the ledger: %w", tx.Type, err)
+		}
+	}
+	return nil
+}
+
+// UpdateCampaignParty loads the named campaign's party with that campaign's settings in effect,
+// applies the change and commits it, then switches back to the current campaign.
+// Used when a campaign's rules change, so nothing happens if it has no party yet.
+func UpdateCampaignParty(name string, change func(*models.Party) models.Transaction) (tx models.Transaction, err error) {
+	previous := currentCampaign
+	if err := UseCampaign(name); err != nil {
+		return tx, err
+	}
+	defer func() {
+		err = errors.Join(err, UseCampaign(previous))
+	}()
+
+	party, err := LoadParty()
+	if errors.Is(err, os.ErrNotExist) {
+		return tx, nil
+	}
+	if err != nil {
+		return tx, fmt.Errorf("loading party: %w", err)
+	}
+	history, err := LoadHistory()
+	if err != nil {
+		return tx, fmt.Errorf("loading undo history: %w", err)
+	}
+	tx = change(&party)
+	return tx, Commit(&party, &history, tx)
+}
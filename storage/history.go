@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"os"
+)
+
+const historyFile = "history.json"
+
+// SaveHistory writes the undo and redo stacks to a JSON file
+func SaveHistory(history *models.History) error {
+	path, err := campaignPath(historyFile)
+	if err != nil {
+		return err
+	}
+	data, err := encodeHistory(history)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// LoadHistory loads the undo and redo stacks, upgrading older save formats.
+// Returns empty stacks if none have been saved.
+func LoadHistory() (models.History, error) {
+	path, err := campaignPath(historyFile)
+	if err != nil {
+		return models.History{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return models.History{}, nil
+	}
+	if err != nil {
+		return models.History{}, err
+	}
+	return decodeHistory(data)
+}
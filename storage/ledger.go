@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"bufio"
+	"dndgoldtracker/models"
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+const ledgerFile = "ledger.jsonl"
+
+// AppendTransaction adds a transaction to the end of the ledger file.
+// Existing entries are never rewritten.
+func AppendTransaction(t models.Transaction) error {
+	path, err := campaignPath(ledgerFile)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// LoadLedger reads every transaction from the ledger file in the order they were recorded
+func LoadLedger() ([]models.Transaction, error) {
+	path, err := campaignPath(ledgerFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ledger []models.Transaction
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var t models.Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return nil, err
+		}
+		ledger = append(ledger, t)
+	}
+	return ledger, scanner.Err()
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"dndgoldtracker/models"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// The save format version written by this build. Bump it and add a migration
+// whenever a change to the models would stop older saves from loading correctly.
+const currentVersion = 3
+
+// A migration upgrades a decoded party from one save format version to the next
+type migration func(party map[string]any) error
+
+// migrations[v] upgrades a party saved at version v to version v+1
+var migrations = []migration{
+	// Version 1 wrapped the party in a document with a version number.
+	// The party itself didn't change.
+	func(party map[string]any) error { return nil },
+
+	// Version 2 gave every member a stable ID. Saves that are only read are never
+	// rewritten, so the IDs are worked out from the members' names to come out the
+	// same on every load.
+	func(party map[string]any) error {
+		seen := make(map[string]int)
+		for _, group := range []string{"ActiveMembers", "InactiveMembers"} {
+			members, _ := party[group].([]any)
+			for _, m := range members {
+				member, ok := m.(map[string]any)
+				if !ok {
+					return fmt.Errorf("invalid member in %s", group)
+				}
+				name, _ := member["Name"].(string)
+				if id, _ := member["ID"].(string); id == "" {
+					member["ID"] = legacyID(name, seen[name])
+				}
+				seen[name]++
+			}
+		}
+		return nil
+	},
+
+	// Version 3 added share weights, everyone starts with a full share
+	func(party map[string]any) error {
+		for _, group := range []string{"ActiveMembers", "InactiveMembers"} {
+			members, _ := party[group].([]any)
+			for _, m := range members {
+				member, ok := m.(map[string]any)
+				if !ok {
+					return fmt.Errorf("invalid member in %s", group)
+				}
+				member["Share"] = models.FullShare
+			}
+		}
+		return nil
+	},
+}
+
+// Returns the ID given to a member saved before members had IDs, from their name and
+// how many members before them in the party had the same name
+func legacyID(name string, occurrence int) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d", name, occurrence))
+	return hex.EncodeToString(sum[:8])
+}
+
+// The document written to party.json
+type partyDocument struct {
+	Version int
+	Party   models.Party
+}
+
+// Encodes a party in the current save format
+func encodeParty(party *models.Party) ([]byte, error) {
+	return json.MarshalIndent(partyDocument{Version: currentVersion, Party: *party}, "", "  ")
+}
+
+// Decodes a party saved in any known version of the save format, upgrading it as needed.
+// Saves from before versioning are a bare party and count as version 0.
+func decodeParty(data []byte) (models.Party, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return models.Party{}, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	version, partyData := 0, json.RawMessage(data)
+	if v, ok := doc["Version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return models.Party{}, fmt.Errorf("%w: invalid version: %v", ErrCorrupt, err)
+		}
+		partyData = doc["Party"]
+	}
+	return migrateParty(partyData, version)
+}
+
+// Upgrades an encoded party from the given version to the current one and decodes it
+func migrateParty(data json.RawMessage, version int) (models.Party, error) {
+	if version < 0 {
+		return models.Party{}, fmt.Errorf("%w: invalid version %d", ErrCorrupt, version)
+	}
+	if version > currentVersion {
+		return models.Party{}, fmt.Errorf("%w: save format version %d, this build supports up to %d", ErrNewerVersion, version, currentVersion)
+	}
+
+	var party models.Party
+	if version == currentVersion {
+		if err := json.Unmarshal(data, &party); err != nil {
+			return party, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return party, nil
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return party, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	for v := version; v < currentVersion; v++ {
+		if err := migrations[v](doc); err != nil {
+			return party, fmt.Errorf("upgrading save from version %d to %d: %w", v, v+1, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return party, err
+	}
+	if err := json.Unmarshal(upgraded, &party); err != nil {
+		return party, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return party, nil
+}
+
+// The document written to history.json. Snapshots hold whole parties, so they
+// are upgraded with the same migrations as party.json.
+type historyDocument struct {
+	Version int
+	Undo    []snapshotFile
+	Redo    []snapshotFile
+}
+
+type snapshotFile struct {
+	Action string
+	Party  json.RawMessage
+}
+
+// Encodes undo history in the current save format
+func encodeHistory(history *models.History) ([]byte, error) {
+	return json.Marshal(struct {
+		Version int
+		models.History
+	}{currentVersion, *history})
+}
+
+// Decodes undo history saved in any known version of the save format.
+// Members of snapshots saved before members had IDs get the IDs they're given in
+// party.json, as both are worked out from the members' names.
+func decodeHistory(data []byte) (models.History, error) {
+	var file historyDocument
+	if err := json.Unmarshal(data, &file); err != nil {
+		return models.History{}, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	decode := func(snapshots []snapshotFile) ([]models.Snapshot, error) {
+		var decoded []models.Snapshot
+		for _, s := range snapshots {
+			party, err := migrateParty(s.Party, file.Version)
+			if err != nil {
+				return nil, err
+			}
+			decoded = append(decoded, models.Snapshot{Action: s.Action, Party: party})
+		}
+		return decoded, nil
+	}
+
+	var history models.History
+	var err error
+	if history.Undo, err = decode(file.Undo); err != nil {
+		return history, err
+	}
+	history.Redo, err = decode(file.Redo)
+	return history, err
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"dndgoldtracker/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Every fixture holds the same party saved in a different version of the save format
+var partyFixtures = []string{
+	"party_v0.json",
+	"party_v1.json",
+	"party_v2.json",
+	"party_v3.json",
+}
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	if len(migrations) != currentVersion {
+		t.Errorf("Expected %d migrations for version %d, got %d", currentVersion, currentVersion, len(migrations))
+	}
+	if len(partyFixtures) != currentVersion+1 {
+		t.Errorf("Expected a fixture for each of the %d save versions, got %d", currentVersion+1, len(partyFixtures))
+	}
+}
+
+func TestDecodePartyFixtures(t *testing.T) {
+	for _, fixture := range partyFixtures {
+		t.Run(fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			party, err := decodeParty(data)
+			if err != nil {
+				t.Fatalf("Couldn't decode %s: %v", fixture, err)
+			}
+			checkFixtureParty(t, party)
+
+			// Saving and loading again gives back the same party
+			encoded, err := encodeParty(&party)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reloaded, err := decodeParty(encoded)
+			if err != nil {
+				t.Fatalf("Couldn't decode the re-encoded party: %v", err)
+			}
+			checkFixtureParty(t, reloaded)
+		})
+	}
+}
+
+func TestDecodeHistoryFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "history_v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := decodeHistory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Undo) != 1 || history.Undo[0].Action != models.CoinAward {
+		t.Fatalf("Expected a single coin award to undo, got %+v", history.Undo)
+	}
+	checkFixtureParty(t, history.Undo[0].Party)
+}
+
+func TestHistoryIDsMatchParty(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "party_v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	party, err := decodeParty(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The party before Fred was deactivated, saved before members had IDs
+	history, err := decodeHistory([]byte(`{"Undo": [{"Action": "GroupChange", "Party": {
+		"ActiveMembers": [{"Name": "Keg"}],
+		"InactiveMembers": [{"Name": "Rowan"}, {"Name": "Fred"}]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[string]string)
+	for _, m := range slices.Concat(party.ActiveMembers, party.InactiveMembers) {
+		ids[m.Name] = m.ID
+	}
+	snapshot := history.Undo[0].Party
+	for _, m := range slices.Concat(snapshot.ActiveMembers, snapshot.InactiveMembers) {
+		if m.ID != ids[m.Name] {
+			t.Errorf("Expected %s to have ID %s in the undo history like in the party, got %s", m.Name, ids[m.Name], m.ID)
+		}
+	}
+}
+
+func TestDecodePartyFromTheFuture(t *testing.T) {
+	if _, err := decodeParty([]byte(`{"Version": 999, "Party": {}}`)); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected ErrNewerVersion for an unknown save version, got %v", err)
+	}
+}
+
+// Checks the party stored in every fixture
+func checkFixtureParty(t *testing.T, party models.Party) {
+	t.Helper()
+	if len(party.ActiveMembers) != 2 || len(party.InactiveMembers) != 1 {
+		t.Fatalf("Expected 2 active and 1 inactive member, got %d and %d", len(party.ActiveMembers), len(party.InactiveMembers))
+	}
+	keg := party.ActiveMembers[0]
+	if keg.Name != "Keg" || keg.Level != 3 || keg.XP != 1050 || keg.Coins[models.Gold] != 43 || keg.Coins[models.Silver] != 11 {
+		t.Errorf("Unexpected Keg: %+v", keg)
+	}
+	if fred := party.ActiveMembers[1]; fred.Name != "Fred" || fred.CoinPriority != 1 {
+		t.Errorf("Unexpected Fred: %+v", fred)
+	}
+	if rowan := party.InactiveMembers[0]; rowan.Name != "Rowan" || rowan.Coins[models.Gold] != 40 {
+		t.Errorf("Unexpected Rowan: %+v", rowan)
+	}
+	if keg.Share != models.FullShare {
+		t.Errorf("Expected Keg to have a full share, got %v", keg.Share)
+	}
+
+	// Every member has a unique ID
+	ids := make(map[string]bool)
+	for _, m := range slices.Concat(party.ActiveMembers, party.InactiveMembers) {
+		if m.ID == "" || ids[m.ID] {
+			t.Errorf("Expected a unique ID for %s, got %q", m.Name, m.ID)
+		}
+		ids[m.ID] = true
+	}
+}
+
+func TestMigrationKeepsExistingIDs(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "party_v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	party, err := decodeParty(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := party.ActiveMembers[0].ID; id != "5f1c9a2e7b3d4e60" {
+		t.Errorf("Expected Keg to keep their saved ID, got %s", id)
+	}
+}
+
+func TestMigratedIDsAreStable(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "party_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := decodeParty(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := decodeParty(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range slices.Concat(first.ActiveMembers, first.InactiveMembers) {
+		if again := slices.Concat(second.ActiveMembers, second.InactiveMembers)[i]; again.ID != m.ID {
+			t.Errorf("Expected %s to get the same ID on every load, got %s and %s", m.Name, m.ID, again.ID)
+		}
+	}
+}
+
+func TestMigrationKeepsShares(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "party_v3.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	party, err := decodeParty(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if share := party.ActiveMembers[1].Share; share != 0.5 {
+		t.Errorf("Expected Fred to keep their half share, got %v", share)
+	}
+}
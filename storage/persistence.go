@@ -2,26 +2,52 @@ package storage
 
 import (
 	"dndgoldtracker/models"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 )
 
-// SaveParty writes party data to a JSON file
+const partyFile = "party.json"
+
+// ErrCorrupt is returned when a save file exists but can't be read
+var ErrCorrupt = errors.New("save file is damaged")
+
+// ErrNewerVersion is returned when a save file was written by a newer build.
+// The file is fine, so it's never set aside or restored over.
+var ErrNewerVersion = errors.New("save file was written by a newer version of dndgoldtracker, update to open it")
+
+// SaveParty writes party data to a JSON file in the current campaign,
+// backing up the previous save first
 func SaveParty(party *models.Party) error {
-	data, err := json.MarshalIndent(party, "", "  ")
+	path, err := campaignPath(partyFile)
+	if err != nil {
+		return err
+	}
+	data, err := encodeParty(party)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile("party.json", data, 0644)
+	if err := backupParty(path); err != nil {
+		return fmt.Errorf("backing up party: %w", err)
+	}
+	return writeFileAtomic(path, data, 0644)
 }
 
-// LoadParty loads party data from a JSON file
+// LoadParty loads party data from a JSON file in the current campaign, upgrading older save formats.
+// Returns an error wrapping ErrCorrupt if the file can't be decoded,
+// or ErrNewerVersion if it's in a save format this build doesn't know.
 func LoadParty() (models.Party, error) {
-	data, err := os.ReadFile("party.json")
+	path, err := campaignPath(partyFile)
+	if err != nil {
+		return models.Party{}, err
+	}
+	return loadPartyFile(path)
+}
+
+func loadPartyFile(path string) (models.Party, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return models.Party{}, err
 	}
-	var party models.Party
-	err = json.Unmarshal(data, &party)
-	return party, err
+	return decodeParty(data)
 }
@@ -1,9 +1,14 @@
 package ui
 
 import (
+	"dndgoldtracker/commands"
 	"dndgoldtracker/models"
 	"dndgoldtracker/storage"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"slices"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/table"
@@ -13,13 +18,61 @@ import (
 )
 
 const (
-	name    = "Name"
-	xp      = "XP"
-	level   = "Level"
-	dotChar = " • "
+	name     = "Name"
+	xp       = "XP"
+	level    = "Level"
+	share    = "Share"
+	percent  = "Treasury %"
+	qty      = "Quantity"
+	value    = "Value"
+	weight   = "Weight (lb)"
+	notes    = "Notes"
+	monsters = "Monsters (CR:count, ...)"
+	session  = "Session"
+	reason   = "Reason"
+	dotChar  = " • "
+)
+
+// Tasks on the main menu, in the order they're listed
+const (
+	choiceMoney = iota
+	choiceExperience
+	choiceAddMember
+	choiceActivateMembers
+	choiceSpend
+	choiceTransfer
+	choiceTreasury
+	choiceLoot
+	choiceCampaigns
 )
 
 var (
+	choiceLabels = []string{
+		"Distribute Money",
+		"Distribute Experience",
+		"Add Member",
+		"Activate/Deactivate Party Members",
+		"Spend Coins",
+		"Transfer Coins",
+		"Party Treasury",
+		"Loot and Inventory",
+		"Campaigns",
+	}
+
+	// Descriptions of the coin split strategies, indexed by strategy
+	strategyLabels = []string{
+		commands.SplitByCoin:  "each coin type on its own",
+		commands.SplitByValue: "equal total value, making change where needed",
+		commands.SplitExact:   "each coin type on its own, breaking leftovers into smaller coins",
+	}
+
+	// Descriptions of the kinds of transfer, indexed by kind
+	transferLabels = []string{
+		commands.GiftTransfer:      "gift",
+		commands.LoanTransfer:      "loan",
+		commands.RepaymentTransfer: "repayment",
+	}
+
 	baseStyle           = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderForeground(lipgloss.Color("240"))
 	subtleStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
 	checkboxStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
@@ -30,63 +83,153 @@ var (
 	noStyle             = lipgloss.NewStyle()
 	helpStyle           = blurredStyle
 	cursorModeHelpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	bannerStyle         = lipgloss.NewStyle().Border(lipgloss.DoubleBorder()).BorderForeground(lipgloss.Color("212")).Padding(0, 2)
 
 	focusedButton   = focusedStyle.Render("[ Submit ]")
 	blurredButton   = fmt.Sprintf("[ %s ]", blurredStyle.Render("Submit"))
-	xpFields        = []string{xp}
+	xpFields        = []string{xp, monsters, reason}
 	newMemberFields = []string{name, xp}
 )
 
 type model struct {
 	activeMemberTable   table.Model
 	inactiveMemberTable table.Model
+	inventoryTable      table.Model
 	party               models.Party
+	history             models.History
 	choice              int
 	chosen              bool
 	coinFocusIndex      int
 	coinInputs          []textinput.Model
+	coinPlan            *commands.CoinPlan // distribution waiting to be confirmed
+	coinStrategy        int
+	settleDebts         bool            // pay back loans out of the borrowers' shares of the next award
+	excluded            map[string]bool // IDs of active members left out of the next award
+	picking             bool            // set while choosing who shares the next award
+	pickerCursor        int
+	claims              map[string]commands.ItemClaim // loot pool items taken as part of the next coin award, by item ID
+	claiming            bool                          // set while choosing who takes which items
+	claimCursor         int
 	xpFocusIndex        int
 	xpInputs            []textinput.Model
+	multiplyEncounter   bool                    // award an encounter's XP after the group size multiplier
+	levelUps            []commands.LevelUpEvent // shown in a banner until dismissed
+	milestoneFocusIndex int
+	milestoneInputs     []textinput.Model
 	memberFocusIndex    int
 	memberInputs        []textinput.Model
+	editMemberID        string // set while a member is being edited
+	editFocusIndex      int
+	editInputs          []textinput.Model
+	deleteMemberID      string // set while a member's deletion is being confirmed
+	spendFocusIndex     int
+	spendInputs         []textinput.Model
+	spender             int // the active member spending coins
+	transferFocusIndex  int
+	transferInputs      []textinput.Model
+	transferFrom        int // the active member giving coins
+	transferTo          int // the active member receiving them
+	transferKind        int
+	treasuryFocusIndex  int
+	treasuryInputs      []textinput.Model
+	treasuryTarget      int // 0 to deposit, otherwise the active member to withdraw to, counting from 1
+	lootFocusIndex      int
+	lootInputs          []textinput.Model
+	addingLoot          bool   // set while a new item is being entered
+	giveItemID          string // set while choosing who gets an item from the loot pool
+	giveTarget          int    // the active member getting the item
+	giveQuantity        int
+	sellItemID          string // set while an item's sale is being confirmed
+	sellHolderID        string // the member selling the item, empty for the loot pool
+	sellQuantity        int
+	salePercent         int
+	sellKeep            bool // the seller keeps the money instead of splitting it
+	showSales           bool // list past sales instead of the inventories
+	campaigns           []models.Campaign
+	campaignCursor      int
+	campaignInput       textinput.Model
+	campaignAction      string // "new" or "rename" while a campaign name is being typed
+	showArchived        bool
 	cursorMode          cursor.Mode
+	pendingTransactions []models.Transaction // group changes waiting to be saved
+	loadErr             error                // set when the saved party couldn't be loaded
+	restore             *storage.Backup      // the backup offered when loading failed
+	status              string
 	quitting            bool
 }
 
 // NewModel initializes the application state
 func NewModel() model {
-	p, err := storage.LoadParty() // Load saved data
-	if err != nil {
-		fmt.Println("Starting new party...")
-		p = models.Party{}
-	}
-
-	newMemberFields = append(newMemberFields, models.CoinOrder...)
-
-	amt := configureTable(p.ActiveMembers)
-	imt := configureTable(p.InactiveMembers)
+	amt := configureTable(nil)
+	imt := configureTable(nil)
 
-	ci := configureInputs(models.CoinOrder)
 	xi := configureInputs(xpFields)
-	mi := configureInputs(newMemberFields)
 
-	return model{
-		party:               p,
+	m := model{
 		activeMemberTable:   amt,
 		inactiveMemberTable: imt,
-		coinInputs:          ci,
+		inventoryTable:      configureInventoryTable(models.Party{}),
 		xpInputs:            xi,
-		memberInputs:        mi,
+		milestoneInputs:     configureInputs([]string{session, reason}),
+		lootInputs:          configureInputs([]string{name, qty, value, weight, notes, reason}),
+		salePercent:         commands.DefaultSalePercent,
+		campaignInput:       configureInputs([]string{"Campaign name"})[0],
+	}
+	m.loadCampaign() // Load saved data
+	return m
+}
+
+// Builds every form with a field for each coin, following the current campaign's currency
+func (m *model) configureCoinInputs() {
+	m.coinInputs = configureInputs(slices.Concat(models.CoinOrder, []string{percent, reason}))
+	m.memberInputs = configureInputs(slices.Concat(newMemberFields, models.CoinOrder))
+	m.editInputs = configureInputs(slices.Concat([]string{name, xp, share}, models.CoinOrder))
+	m.spendInputs = configureInputs(slices.Concat(models.CoinOrder, []string{reason}))
+	m.transferInputs = configureInputs(slices.Concat(models.CoinOrder, []string{reason}))
+	m.treasuryInputs = configureInputs(slices.Concat(models.CoinOrder, []string{reason}))
+}
+
+// Loads the current campaign's party and undo history, replacing whatever was loaded before.
+// The coin forms are rebuilt in case the campaign uses a different currency.
+func (m *model) loadCampaign() {
+	m.loadErr = nil
+	m.restore = nil
+	m.configureCoinInputs()
+	p, err := storage.LoadParty()
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Starting new party: %v", err)
+		m.status = "Starting new party..."
+		p = models.Party{}
+	} else if err != nil {
+		// Don't start over on top of a damaged save, offer a backup instead.
+		// A save from a newer version isn't damaged, so there's nothing to offer.
+		log.Printf("Couldn't load party: %v", err)
+		m.loadErr = err
+		if b, _, err := storage.LatestValidBackup(); err == nil && !errors.Is(m.loadErr, storage.ErrNewerVersion) {
+			m.restore = &b
+		}
+		p = models.Party{}
 	}
+
+	h, err := storage.LoadHistory()
+	if err != nil {
+		log.Printf("Couldn't load undo history: %v", err)
+	}
+
+	m.party = p
+	m.history = h
+	m.pendingTransactions = nil
+	m.excluded = nil
+	m.refreshTables()
 }
 
 func (m model) Init() tea.Cmd { return nil }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	// Make sure these keys always quit
+	// Make sure these keys always quit. q is left alone while typing.
 	if msg, ok := msg.(tea.KeyMsg); ok {
 		k := msg.String()
-		if k == "q" || k == "esc" || k == "ctrl+c" {
+		if (k == "q" && !m.typing()) || k == "esc" || k == "ctrl+c" {
 			m.quitting = true
 			return m, tea.Quit
 		}
@@ -94,24 +237,57 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Hand off the message and model to the appropriate update function for the
 	// appropriate view based on the current state.
+	if m.loadErr != nil {
+		return updateRecovery(msg, m)
+	}
+	if len(m.levelUps) > 0 {
+		return updateLevelUps(msg, m)
+	}
 	if !m.chosen {
 		return updateChoices(msg, m)
 	}
 
 	switch m.choice {
-	case 0:
+	case choiceMoney:
 		return updateMoney(msg, m)
-	case 1:
+	case choiceExperience:
 		return updateExperience(msg, m)
-	case 2:
+	case choiceAddMember:
 		return updateAddMember(msg, m)
-	case 3:
+	case choiceActivateMembers:
 		return updateActivateMembers(msg, m)
+	case choiceSpend:
+		return updateSpend(msg, m)
+	case choiceTransfer:
+		return updateTransfer(msg, m)
+	case choiceTreasury:
+		return updateTreasury(msg, m)
+	case choiceLoot:
+		return updateLoot(msg, m)
+	case choiceCampaigns:
+		return updateCampaigns(msg, m)
 	default:
 		return m, nil
 	}
 }
 
+// Reports whether the current view has text inputs that should receive every key
+func (m model) typing() bool {
+	if !m.chosen {
+		return false
+	}
+	switch m.choice {
+	case choiceActivateMembers:
+		return m.editMemberID != ""
+	case choiceLoot:
+		return m.addingLoot
+	case choiceCampaigns:
+		return m.campaignAction != ""
+	default:
+		return true
+	}
+}
+
 // The main view, which just calls the appropriate sub-view
 func (m model) View() string {
 	var s string
@@ -119,18 +295,38 @@ func (m model) View() string {
 		return "\n  See you later!\n\n"
 	}
 
-	if !m.chosen {
+	if m.loadErr != nil {
+		s = recoveryView(m)
+	} else if len(m.levelUps) > 0 {
+		s = levelUpView(m)
+	} else if !m.chosen {
 		s = choicesView(m)
 	} else {
 		switch m.choice {
-		case 0:
+		case choiceMoney:
 			s = moneyView(m)
-		case 1:
+		case choiceExperience:
 			s = xpView(m)
-		case 2:
+		case choiceAddMember:
 			s = addMemberView(m)
-		case 3:
-			s = activateMemberView(m)
+		case choiceActivateMembers:
+			if m.editMemberID != "" {
+				s = editMemberView(m)
+			} else if m.deleteMemberID != "" {
+				s = deleteMemberView(m)
+			} else {
+				s = activateMemberView(m)
+			}
+		case choiceSpend:
+			s = spendView(m)
+		case choiceTransfer:
+			s = transferView(m)
+		case choiceTreasury:
+			s = treasuryView(m)
+		case choiceLoot:
+			s = lootView(m)
+		case choiceCampaigns:
+			s = campaignsView(m)
 		default:
 			s = "Don't do that"
 		}
@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"dndgoldtracker/models"
+	"testing"
+)
+
+func TestRefreshTablesWithFewerCoins(t *testing.T) {
+	t.Cleanup(func() { models.UseCurrency(models.Currencies[0]) })
+
+	m := model{
+		activeMemberTable:   configureTable(nil),
+		inactiveMemberTable: configureTable(nil),
+		inventoryTable:      configureInventoryTable(models.Party{}),
+		party: models.Party{
+			ActiveMembers:   []models.Member{{ID: "keg", Name: "Keg", Level: 1, Share: 1, Coins: map[string]int{models.Gold: 3}}},
+			InactiveMembers: []models.Member{{ID: "rowan", Name: "Rowan", Level: 1, Share: 1}},
+			Treasury:        map[string]int{models.Silver: 4},
+		},
+	}
+	m.refreshTables()
+
+	gsc, _ := models.FindCurrency("gsc")
+	models.UseCurrency(gsc)
+	m.refreshTables()
+
+	for _, row := range append(m.activeMemberTable.Rows(), m.inactiveMemberTable.Rows()...) {
+		if len(row) != len(m.activeMemberTable.Columns()) {
+			t.Errorf("Expected a cell for each of the %d columns, got %v", len(m.activeMemberTable.Columns()), row)
+		}
+	}
+	m.activeMemberTable.View()
+}
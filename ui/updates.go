@@ -4,10 +4,13 @@ import (
 	"dndgoldtracker/commands"
 	"dndgoldtracker/models"
 	"dndgoldtracker/storage"
+	"errors"
 	"fmt"
 	"log"
 	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
@@ -22,25 +25,101 @@ func updateChoices(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "j", "down":
 			m.choice++
-			if m.choice > 3 {
+			if m.choice >= len(choiceLabels) {
 				m.choice = 0
 			}
 		case "k", "up":
 			m.choice--
 			if m.choice < 0 {
-				m.choice = 3
+				m.choice = len(choiceLabels) - 1
 			}
 		case "enter":
 			m.chosen = true
+			m.status = ""
+			if m.choice == choiceCampaigns {
+				m.refreshCampaigns()
+			}
 			return m, nil
+		case "u":
+			tx, ok := commands.Undo(&m.history, &m.party)
+			if !ok {
+				m.status = "Nothing to undo"
+				return m, nil
+			}
+			m.status = "Undid " + tx.Reason
+			saveUpdateReset(&m, tx)
+		case "r":
+			tx, ok := commands.Redo(&m.history, &m.party)
+			if !ok {
+				m.status = "Nothing to redo"
+				return m, nil
+			}
+			m.status = "Redid " + tx.Reason
+			saveUpdateReset(&m, tx)
+		}
+	}
+
+	return m, nil
+}
+
+// Update loop for recovering from a party file that couldn't be loaded.
+// A party saved by a newer version is left alone, so only quitting is offered.
+func updateRecovery(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if errors.Is(m.loadErr, storage.ErrNewerVersion) {
+		return m, nil
+	}
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y":
+			if m.restore == nil {
+				return m, nil
+			}
+			restored := *m.restore
+			if err := storage.RestoreBackup(restored); err != nil {
+				log.Printf("Couldn't restore backup: %v", err)
+				m.loadErr = err
+				return m, nil
+			}
+			m.loadCampaign()
+			m.status = "Restored backup from " + restored.Created.Format(time.DateTime)
+		case "n":
+			aside, err := storage.SetAsideParty()
+			if err != nil {
+				log.Printf("Couldn't set aside damaged party: %v", err)
+				m.loadErr = err
+				return m, nil
+			}
+			m.loadCampaign()
+			if aside != "" {
+				m.status = "Started a new party, the damaged save was kept as " + aside
+			}
 		}
 	}
 
 	return m, nil
 }
 
+// Update loop for the banner announcing who went up a level, which any key but quitting dismisses
+func updateLevelUps(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.levelUps = nil
+	}
+	return m, nil
+}
+
 // Update loop for updating party money
 func updateMoney(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.coinPlan != nil {
+		return updateCoinConfirm(msg, m)
+	}
+	if m.picking {
+		return updateParticipants(msg, m)
+	}
+	if m.claiming {
+		return updateClaims(msg, m)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -50,6 +129,26 @@ func updateMoney(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 			cmds = changeCursorMode(m.xpInputs, &m.cursorMode)
 
 			return m, tea.Batch(cmds...)
+		// Cycle through splitting by coin type, by value and exactly
+		case "ctrl+t":
+			m.coinStrategy = (m.coinStrategy + 1) % len(strategyLabels)
+			return m, nil
+		// Choose who shares this award
+		case "ctrl+p":
+			m.picking = true
+			return m, nil
+		// Choose who takes items from the loot pool as part of their share
+		case "ctrl+l":
+			if len(m.party.Loot) == 0 {
+				m.status = "The loot pool is empty"
+				return m, nil
+			}
+			m.claiming = true
+			return m, nil
+		// Pay back loans out of the borrowers' shares
+		case "ctrl+s":
+			m.settleDebts = !m.settleDebts
+			return m, nil
 		// Set focus to next input
 		case "enter":
 			// Did the user press enter while the submit button was focused?
@@ -69,11 +168,28 @@ func updateMoney(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 					}
 				}
 
-				// Distribute the coins to the party
-				commands.DistributeCoins(&m.party, coinMap)
-				saveUpdateReset(&m)
+				treasuryPercent, err := strconv.Atoi(inputValue(m.coinInputs, percent))
+				if err != nil || treasuryPercent < 0 || treasuryPercent > 100 {
+					m.status = "Treasury % must be a whole number from 0 to 100"
+					return m, nil
+				}
 
-				m.chosen = false
+				ids := m.participantIDs()
+				if ids != nil && len(ids) == 0 {
+					m.status = "Pick at least one member to share the coins"
+					return m, nil
+				}
+
+				// Show the plan so it can be confirmed before anything changes
+				opts := commands.CoinOptions{Strategy: m.coinStrategy, Participants: ids, TreasuryPercent: treasuryPercent, SettleDebts: m.settleDebts}
+				plan := commands.PlanCoinDistribution(&m.party, coinMap, opts)
+				if len(m.claims) > 0 {
+					if plan, err = commands.PlanAllocation(&m.party, coinMap, opts, m.claimList()); err != nil {
+						m.status = err.Error()
+						return m, nil
+					}
+				}
+				m.coinPlan = &plan
 				return m, nil
 			}
 			// Cycle indexes
@@ -95,8 +211,38 @@ func updateMoney(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// Update loop for accepting or cancelling a planned coin distribution
+func updateCoinConfirm(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y", "enter":
+			// Distribute the coins to the party
+			commands.Record(&m.history, &m.party, models.CoinAward)
+			tx := commands.ApplyCoinPlan(&m.party, *m.coinPlan)
+			tx.Reason = inputValue(m.coinInputs, reason)
+			saveUpdateReset(&m, tx)
+
+			m.coinPlan = nil
+			m.chosen = false
+		case "n", "backspace":
+			// Go back to the coin inputs without changing anything
+			m.coinPlan = nil
+		}
+	}
+
+	return m, nil
+}
+
 // Update loop for updating party experience
 func updateExperience(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.picking {
+		return updateParticipants(msg, m)
+	}
+	if models.UsesMilestones() {
+		return updateMilestone(msg, m)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -105,6 +251,14 @@ func updateExperience(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 			var cmds []tea.Cmd
 			cmds = changeCursorMode(m.xpInputs, &m.cursorMode)
 			return m, tea.Batch(cmds...)
+		// Choose who shares this award
+		case "ctrl+p":
+			m.picking = true
+			return m, nil
+		// Award an encounter's XP with or without the group size multiplier
+		case "ctrl+e":
+			m.multiplyEncounter = !m.multiplyEncounter
+			return m, nil
 
 		// Set focus to next input
 		case "enter":
@@ -119,9 +273,26 @@ func updateExperience(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 					log.Println("Invalid input for experience, try again")
 					return m, nil
 				}
+				encounter, err := m.encounter()
+				if err != nil {
+					m.status = err.Error()
+					return m, nil
+				}
+				if encounter != nil {
+					xp = encounter.Award(m.multiplyEncounter)
+				}
 
-				commands.DistributeExperience(&m.party, xp)
-				saveUpdateReset(&m)
+				ids := m.participantIDs()
+				if ids != nil && len(ids) == 0 {
+					m.status = "Pick at least one member to share the experience"
+					return m, nil
+				}
+
+				commands.Record(&m.history, &m.party, models.XPAward)
+				tx, levelUps := commands.DistributeExperienceTo(&m.party, xp, ids)
+				tx.Reason = inputValue(m.xpInputs, reason)
+				saveUpdateReset(&m, tx)
+				m.levelUps = levelUps
 
 				m.chosen = false
 				return m, nil
@@ -143,6 +314,147 @@ func updateExperience(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// Update loop for levelling up members who reached a milestone
+func updateMilestone(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		// Change cursor mode
+		case "ctrl+r":
+			var cmds []tea.Cmd
+			cmds = changeCursorMode(m.milestoneInputs, &m.cursorMode)
+			return m, tea.Batch(cmds...)
+		// Choose who goes up a level
+		case "ctrl+p":
+			m.picking = true
+			return m, nil
+
+		// Set focus to next input
+		case "enter":
+			// Did the user press enter while the submit button was focused?
+			// If so, level up the members.
+			if m.milestoneFocusIndex == len(m.milestoneInputs) {
+				handleUnsetInputs(m.milestoneInputs)
+				sessionNumber, err := strconv.Atoi(inputValue(m.milestoneInputs, session))
+				if err != nil {
+					m.status = "Invalid session number, try again"
+					return m, nil
+				}
+				ids := m.participantIDs()
+				if ids != nil && len(ids) == 0 {
+					m.status = "Pick at least one member to level up"
+					return m, nil
+				}
+
+				before := m.party.Clone()
+				tx, err := commands.LevelUp(&m.party, ids, sessionNumber, inputValue(m.milestoneInputs, reason))
+				if err != nil {
+					m.status = err.Error()
+					return m, nil
+				}
+				commands.Record(&m.history, &before, models.LevelChanged)
+				saveUpdateReset(&m, tx)
+
+				m.chosen = false
+				return m, nil
+			}
+		case "up", "shift-tab", "down":
+			s := msg.String()
+			if s == "down" {
+				m.milestoneFocusIndex++
+			} else {
+				m.milestoneFocusIndex--
+			}
+			cmds := updateFocusIndex(&m.milestoneFocusIndex, m.milestoneInputs)
+			return m, tea.Batch(cmds...)
+		}
+	}
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg, m.milestoneInputs)
+
+	return m, cmd
+}
+
+// Update loop for ticking which active members share the next coin or XP award
+func updateParticipants(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "k":
+			m.pickerCursor = max(m.pickerCursor-1, 0)
+		case "down", "j":
+			m.pickerCursor = min(m.pickerCursor+1, max(len(m.party.ActiveMembers)-1, 0))
+		case " ", "x":
+			if m.pickerCursor < len(m.party.ActiveMembers) {
+				id := m.party.ActiveMembers[m.pickerCursor].ID
+				if m.excluded == nil {
+					m.excluded = make(map[string]bool)
+				}
+				m.excluded[id] = !m.excluded[id]
+			}
+		case "a":
+			m.excluded = nil
+		case "enter", "ctrl+p":
+			m.picking = false
+			m.status = ""
+		}
+	}
+	return m, nil
+}
+
+// Update loop for choosing who takes which loot pool items as part of their share.
+// Each item cycles through nobody and then each active member, taking the whole stack unless told otherwise.
+func updateClaims(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if len(m.party.Loot) == 0 || len(m.party.ActiveMembers) == 0 {
+		m.claiming = false
+		return m, nil
+	}
+	m.claimCursor = min(m.claimCursor, len(m.party.Loot)-1)
+	item := m.party.Loot[m.claimCursor]
+	claim, claimed := m.claims[item.ID]
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "k":
+			m.claimCursor = max(m.claimCursor-1, 0)
+		case "down", "j":
+			m.claimCursor = min(m.claimCursor+1, len(m.party.Loot)-1)
+		case "right", "l", "left", "h":
+			// Position 0 is nobody, then each active member in turn
+			positions := len(m.party.ActiveMembers) + 1
+			pos := 0
+			if claimed {
+				pos = commands.FindMember(m.party.ActiveMembers, claim.MemberID) + 1
+			}
+			if msg.String() == "right" || msg.String() == "l" {
+				pos = (pos + 1) % positions
+			} else {
+				pos = (pos + positions - 1) % positions
+			}
+			if m.claims == nil {
+				m.claims = make(map[string]commands.ItemClaim)
+			}
+			if pos == 0 {
+				delete(m.claims, item.ID)
+			} else {
+				m.claims[item.ID] = commands.ItemClaim{MemberID: m.party.ActiveMembers[pos-1].ID, ItemID: item.ID, Quantity: item.Quantity}
+			}
+		case "+", "-":
+			if claimed {
+				if msg.String() == "+" {
+					claim.Quantity = min(claim.Quantity+1, item.Quantity)
+				} else {
+					claim.Quantity = max(claim.Quantity-1, 1)
+				}
+				m.claims[item.ID] = claim
+			}
+		case "enter", "ctrl+l":
+			m.claiming = false
+			m.status = ""
+		}
+	}
+	return m, nil
+}
+
 // Update loop for adding members
 func updateAddMember(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -185,8 +497,9 @@ func updateAddMember(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 				}
 
 				// Add the new party Member
-				commands.AddMember(&m.party, name, xp, newMemberMoney)
-				saveUpdateReset(&m)
+				commands.Record(&m.history, &m.party, models.MemberAdded)
+				tx := commands.AddMember(&m.party, name, xp, newMemberMoney)
+				saveUpdateReset(&m, tx)
 
 				m.chosen = false
 				return m, nil
@@ -212,11 +525,41 @@ func updateAddMember(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 
 // Update loop for activating or deactivating members
 func updateActivateMembers(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.editMemberID != "" {
+		return updateEditMember(msg, m)
+	}
+	if m.deleteMemberID != "" {
+		return updateDeleteMember(msg, m)
+	}
+
 	var activeCmd tea.Cmd
 	var inactiveCmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.status = ""
 		switch msg.String() {
+		case "e":
+			member, ok := m.focusedMember()
+			if !ok {
+				return m, nil
+			}
+			m.editMemberID = member.ID
+			setInputValue(m.editInputs, name, member.Name)
+			setInputValue(m.editInputs, xp, strconv.Itoa(member.XP))
+			setInputValue(m.editInputs, share, formatShare(member.Share))
+			for _, coinType := range models.CoinOrder {
+				setInputValue(m.editInputs, coinType, strconv.Itoa(member.Coins[coinType]))
+			}
+			m.editFocusIndex = 0
+			cmds := updateFocusIndex(&m.editFocusIndex, m.editInputs)
+			return m, tea.Batch(cmds...)
+		case "d":
+			member, ok := m.focusedMember()
+			if !ok {
+				return m, nil
+			}
+			m.deleteMemberID = member.ID
+			return m, nil
 		case "tab":
 			// Change table focus with tab
 			if m.activeMemberTable.Focused() {
@@ -230,20 +573,21 @@ func updateActivateMembers(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 			}
 		case "enter":
 			var selectedTable *table.Model
-			var selectedMembers *[]models.Member
-			var unselectedMembers *[]models.Member
+			var selectedMembers []models.Member
+			var dstGroup string
 			// Move the selected member from their current table to the new one
 			if m.activeMemberTable.Focused() {
 				selectedTable = &m.activeMemberTable
-				selectedMembers = &m.party.ActiveMembers
-				unselectedMembers = &m.party.InactiveMembers
+				selectedMembers = m.party.ActiveMembers
+				dstGroup = models.InactiveGroup
 			} else {
 				selectedTable = &m.inactiveMemberTable
-				selectedMembers = &m.party.InactiveMembers
-				unselectedMembers = &m.party.ActiveMembers
+				selectedMembers = m.party.InactiveMembers
+				dstGroup = models.ActiveGroup
 			}
 			// activate/deactivate member
-			if len(selectedTable.SelectedRow()) <= 0 {
+			member, ok := selectedMember(*selectedTable, selectedMembers)
+			if !ok {
 				// Unselected cursor or empty table
 				// Set cursor to first element and return
 				log.Println("Unselected cursor thing")
@@ -251,19 +595,19 @@ func updateActivateMembers(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
-			memberName := selectedTable.SelectedRow()[0]
-			if m.activeMemberTable.Focused() {
-				log.Printf("Moving %s from %s to %s", memberName, "Active", "Inactive")
-			} else {
-				log.Printf("Moving %s from %s to %s", memberName, "Inactive", "Active")
-			}
+			log.Printf("Moving %s (%s) to %s", member.Name, member.ID, dstGroup)
 
-			memberIndex := slices.IndexFunc(*selectedMembers, func(m models.Member) bool { return m.Name == memberName })
-			commands.ChangeMemberGroup(selectedMembers, unselectedMembers, memberIndex)
-			m.activeMemberTable.SetRows(membersToRows(m.party.ActiveMembers))
-			m.inactiveMemberTable.SetRows(membersToRows(m.party.InactiveMembers))
+			commands.Record(&m.history, &m.party, models.GroupChange)
+			tx, err := commands.ChangeMemberGroup(&m.party, member.ID, dstGroup)
+			if err != nil {
+				log.Println(err)
+				return m, nil
+			}
+			m.pendingTransactions = append(m.pendingTransactions, tx)
+			m.refreshTables()
 		case "s":
-			storage.SaveParty(&m.party)
+			saveParty(&m, m.pendingTransactions...)
+			m.pendingTransactions = nil
 			m.chosen = false
 		}
 
@@ -273,3 +617,674 @@ func updateActivateMembers(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 	m.inactiveMemberTable, inactiveCmd = m.inactiveMemberTable.Update(msg)
 	return m, tea.Batch(activeCmd, inactiveCmd)
 }
+
+// Update loop for editing a member's name, XP and wallet
+func updateEditMember(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+r":
+			cmds := changeCursorMode(m.editInputs, &m.cursorMode)
+			return m, tea.Batch(cmds...)
+		case "ctrl+x":
+			m.editMemberID = ""
+			m.status = ""
+			return m, nil
+		case "enter":
+			if m.editFocusIndex != len(m.editInputs) {
+				return m, nil
+			}
+			edit := commands.MemberEdit{Name: strings.TrimSpace(inputValue(m.editInputs, name)), Coins: make(map[string]int)}
+			var err error
+			if edit.XP, err = strconv.Atoi(inputValue(m.editInputs, xp)); err != nil {
+				m.status = "XP must be a whole number"
+				return m, nil
+			}
+			if edit.Share, err = strconv.ParseFloat(inputValue(m.editInputs, share), 64); err != nil {
+				m.status = "Share must be a number, e.g. 0.5 for a half share"
+				return m, nil
+			}
+			for _, coinType := range models.CoinOrder {
+				if edit.Coins[coinType], err = strconv.Atoi(inputValue(m.editInputs, coinType)); err != nil {
+					m.status = coinType + " must be a whole number"
+					return m, nil
+				}
+			}
+
+			before := m.party.Clone()
+			tx, err := commands.EditMember(&m.party, m.editMemberID, edit)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, models.MemberEdited)
+			m.saveMemberChange(tx)
+			m.editMemberID = ""
+			m.status = "Saved " + edit.Name
+			return m, nil
+		case "up", "shift-tab", "down":
+			if msg.String() == "down" {
+				m.editFocusIndex++
+			} else {
+				m.editFocusIndex--
+			}
+			cmds := updateFocusIndex(&m.editFocusIndex, m.editInputs)
+			return m, tea.Batch(cmds...)
+		}
+	}
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg, m.editInputs)
+
+	return m, cmd
+}
+
+// Update loop for confirming a member's deletion and choosing what happens to their wallet
+func updateDeleteMember(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		wallet := -1
+		switch msg.String() {
+		case "s":
+			wallet = commands.SplitWallet
+		case "t":
+			wallet = commands.TreasuryWallet
+		case "x":
+			wallet = commands.DiscardWallet
+		case "n", "backspace":
+			m.deleteMemberID = ""
+			return m, nil
+		}
+		if wallet < 0 {
+			return m, nil
+		}
+
+		before := m.party.Clone()
+		tx, err := commands.DeleteMember(&m.party, m.deleteMemberID, wallet)
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		commands.Record(&m.history, &before, models.MemberRemoved)
+		m.saveMemberChange(tx)
+		m.deleteMemberID = ""
+		m.activeMemberTable.SetCursor(0)
+		m.inactiveMemberTable.SetCursor(0)
+	}
+
+	return m, nil
+}
+
+// Update loop for recording a member spending coins
+func updateSpend(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.spender >= len(m.party.ActiveMembers) {
+		m.spender = 0
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+r":
+			cmds := changeCursorMode(m.spendInputs, &m.cursorMode)
+			return m, tea.Batch(cmds...)
+		// Cycle through the active members
+		case "ctrl+n":
+			if len(m.party.ActiveMembers) > 0 {
+				m.spender = (m.spender + 1) % len(m.party.ActiveMembers)
+			}
+			return m, nil
+		case "enter":
+			if m.spendFocusIndex != len(m.spendInputs) {
+				return m, nil
+			}
+			if len(m.party.ActiveMembers) == 0 {
+				m.status = "There are no active members to spend coins"
+				return m, nil
+			}
+			handleUnsetInputs(m.spendInputs)
+			price := make(map[string]int)
+			for _, coinType := range models.CoinOrder {
+				amount, err := strconv.Atoi(inputValue(m.spendInputs, coinType))
+				if err != nil || amount < 0 {
+					m.status = coinType + " must be a whole number of at least 0"
+					return m, nil
+				}
+				price[coinType] = amount
+			}
+
+			before := m.party.Clone()
+			tx, err := commands.Spend(&m.party, m.party.ActiveMembers[m.spender].ID, price)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, models.Purchase)
+			tx.Reason = inputValue(m.spendInputs, reason)
+			saveUpdateReset(&m, tx)
+
+			m.status = describePurchase(tx.Entries[0])
+			m.chosen = false
+			return m, nil
+		case "up", "shift-tab", "down":
+			if msg.String() == "down" {
+				m.spendFocusIndex++
+			} else {
+				m.spendFocusIndex--
+			}
+			cmds := updateFocusIndex(&m.spendFocusIndex, m.spendInputs)
+			return m, tea.Batch(cmds...)
+		}
+	}
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg, m.spendInputs)
+
+	return m, cmd
+}
+
+// Update loop for giving, lending or paying back coins between two members
+func updateTransfer(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	from, to := m.transferPair()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+r":
+			cmds := changeCursorMode(m.transferInputs, &m.cursorMode)
+			return m, tea.Batch(cmds...)
+		// Cycle through the active members giving and receiving the coins
+		case "ctrl+n":
+			m.transferFrom = from + 1
+			return m, nil
+		case "ctrl+o":
+			m.transferTo = to + 1
+			return m, nil
+		// Cycle between a gift, a loan and a repayment
+		case "ctrl+l":
+			m.transferKind = (m.transferKind + 1) % len(transferLabels)
+			return m, nil
+		case "enter":
+			if m.transferFocusIndex != len(m.transferInputs) {
+				return m, nil
+			}
+			if len(m.party.ActiveMembers) < 2 {
+				m.status = "Transfers need at least two active members"
+				return m, nil
+			}
+			handleUnsetInputs(m.transferInputs)
+			money := make(map[string]int)
+			for _, coinType := range models.CoinOrder {
+				amount, err := strconv.Atoi(inputValue(m.transferInputs, coinType))
+				if err != nil || amount < 0 {
+					m.status = coinType + " must be a whole number of at least 0"
+					return m, nil
+				}
+				money[coinType] = amount
+			}
+
+			before := m.party.Clone()
+			giver, receiver := m.party.ActiveMembers[from], m.party.ActiveMembers[to]
+			tx, err := commands.TransferCoins(&m.party, giver.ID, receiver.ID, money, m.transferKind)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, tx.Type)
+			tx.Reason = inputValue(m.transferInputs, reason)
+			saveUpdateReset(&m, tx)
+
+			m.status = giver.Name + " gave " + receiver.Name + " " + formatCoins(money)
+			m.transferKind = commands.GiftTransfer
+			m.chosen = false
+			return m, nil
+		case "up", "shift-tab", "down":
+			if msg.String() == "down" {
+				m.transferFocusIndex++
+			} else {
+				m.transferFocusIndex--
+			}
+			cmds := updateFocusIndex(&m.transferFocusIndex, m.transferInputs)
+			return m, tea.Batch(cmds...)
+		}
+	}
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg, m.transferInputs)
+
+	return m, cmd
+}
+
+// Update loop for depositing coins into the party treasury or withdrawing them to a member
+func updateTreasury(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.treasuryTarget > len(m.party.ActiveMembers) {
+		m.treasuryTarget = 0
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+r":
+			cmds := changeCursorMode(m.treasuryInputs, &m.cursorMode)
+			return m, tea.Batch(cmds...)
+		// Cycle between depositing and withdrawing to each active member
+		case "ctrl+n":
+			m.treasuryTarget = (m.treasuryTarget + 1) % (len(m.party.ActiveMembers) + 1)
+			return m, nil
+		case "enter":
+			if m.treasuryFocusIndex != len(m.treasuryInputs) {
+				return m, nil
+			}
+			handleUnsetInputs(m.treasuryInputs)
+			money := make(map[string]int)
+			for _, coinType := range models.CoinOrder {
+				amount, err := strconv.Atoi(inputValue(m.treasuryInputs, coinType))
+				if err != nil || amount < 0 {
+					m.status = coinType + " must be a whole number of at least 0"
+					return m, nil
+				}
+				money[coinType] = amount
+			}
+
+			before := m.party.Clone()
+			var tx models.Transaction
+			if m.treasuryTarget == 0 {
+				tx = commands.Deposit(&m.party, money)
+			} else {
+				var err error
+				member := m.party.ActiveMembers[m.treasuryTarget-1]
+				if tx, err = commands.Withdraw(&m.party, member.ID, money); err != nil {
+					m.status = err.Error()
+					return m, nil
+				}
+			}
+			commands.Record(&m.history, &before, tx.Type)
+			tx.Reason = inputValue(m.treasuryInputs, reason)
+			saveUpdateReset(&m, tx)
+
+			m.status = "Treasury holds " + formatCoins(m.party.Treasury)
+			m.treasuryTarget = 0
+			m.chosen = false
+			return m, nil
+		case "up", "shift-tab", "down":
+			if msg.String() == "down" {
+				m.treasuryFocusIndex++
+			} else {
+				m.treasuryFocusIndex--
+			}
+			cmds := updateFocusIndex(&m.treasuryFocusIndex, m.treasuryInputs)
+			return m, tea.Batch(cmds...)
+		}
+	}
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg, m.treasuryInputs)
+
+	return m, cmd
+}
+
+// Update loop for the loot pool and member inventories
+func updateLoot(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.addingLoot {
+		return updateAddLoot(msg, m)
+	}
+	if m.giveItemID != "" {
+		return updateGiveItem(msg, m)
+	}
+	if m.sellItemID != "" {
+		return updateSellItem(msg, m)
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.status = ""
+		selected, ok := selectedEntry(m.inventoryTable, inventoryEntries(m.party))
+		switch msg.String() {
+		case "a":
+			m.addingLoot = true
+			m.lootFocusIndex = 0
+			cmds := updateFocusIndex(&m.lootFocusIndex, m.lootInputs)
+			return m, tea.Batch(cmds...)
+		case "g", "enter":
+			if !ok || selected.holderID != "" {
+				m.status = "Pick an item in the loot pool to give it to someone"
+				return m, nil
+			}
+			if len(m.party.ActiveMembers) == 0 {
+				m.status = "There are no active members to give items to"
+				return m, nil
+			}
+			m.giveItemID = selected.item.ID
+			m.giveTarget = 0
+			m.giveQuantity = selected.item.Quantity
+			return m, nil
+		case "r":
+			if !ok || selected.holderID == "" {
+				m.status = "Pick an item a member holds to return it to the loot pool"
+				return m, nil
+			}
+			before := m.party.Clone()
+			tx, err := commands.ReturnItem(&m.party, selected.holderID, selected.item.ID, selected.item.Quantity)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, tx.Type)
+			saveUpdateReset(&m, tx)
+			m.status = fmt.Sprintf("%s put %d %s back in the loot pool", selected.holder, selected.item.Quantity, selected.item.Name)
+			return m, nil
+		case "t":
+			if !ok || selected.holderID == "" {
+				m.status = "Only items a member holds can be attuned"
+				return m, nil
+			}
+			before := m.party.Clone()
+			tx, err := commands.SetAttunement(&m.party, selected.holderID, selected.item.ID, !selected.item.Attuned)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, tx.Type)
+			saveUpdateReset(&m, tx)
+			if selected.item.Attuned {
+				m.status = selected.holder + " is no longer attuned to " + selected.item.Name
+			} else {
+				m.status = selected.holder + " is attuned to " + selected.item.Name
+			}
+			return m, nil
+		case "s":
+			if !ok {
+				return m, nil
+			}
+			if selected.holderID != "" && commands.FindMember(m.party.ActiveMembers, selected.holderID) < 0 {
+				m.status = "Activate " + selected.holder + " before selling their items"
+				return m, nil
+			}
+			m.sellItemID = selected.item.ID
+			m.sellHolderID = selected.holderID
+			m.sellQuantity = selected.item.Quantity
+			m.sellKeep = false
+			return m, nil
+		case "h":
+			m.showSales = !m.showSales
+			return m, nil
+		case "backspace":
+			m.chosen = false
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inventoryTable, cmd = m.inventoryTable.Update(msg)
+	return m, cmd
+}
+
+// Update loop for entering a newly found item
+func updateAddLoot(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+r":
+			cmds := changeCursorMode(m.lootInputs, &m.cursorMode)
+			return m, tea.Batch(cmds...)
+		case "ctrl+x":
+			resetInputs(m.lootInputs)
+			m.addingLoot = false
+			m.status = ""
+			return m, nil
+		case "enter":
+			if m.lootFocusIndex != len(m.lootInputs) {
+				return m, nil
+			}
+			item := models.Item{Name: inputValue(m.lootInputs, name), Quantity: 1, Notes: inputValue(m.lootInputs, notes)}
+			var err error
+			if v := inputValue(m.lootInputs, qty); v != "" {
+				if item.Quantity, err = strconv.Atoi(v); err != nil {
+					m.status = "Quantity must be a whole number"
+					return m, nil
+				}
+			}
+			if v := inputValue(m.lootInputs, value); v != "" {
+				if item.ValueGP, err = strconv.Atoi(v); err != nil {
+					m.status = "Value must be a whole number of " + models.CurrentCurrency.StandardCoin().Name
+					return m, nil
+				}
+			}
+			if v := inputValue(m.lootInputs, weight); v != "" {
+				if item.Weight, err = strconv.ParseFloat(v, 64); err != nil {
+					m.status = "Weight must be a number of pounds"
+					return m, nil
+				}
+			}
+
+			before := m.party.Clone()
+			tx, err := commands.AddLoot(&m.party, item)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, models.LootAdded)
+			tx.Reason = inputValue(m.lootInputs, reason)
+			saveUpdateReset(&m, tx)
+
+			m.status = fmt.Sprintf("Added %d %s to the loot pool", item.Quantity, tx.Entries[0].Item)
+			m.addingLoot = false
+			return m, nil
+		case "up", "shift-tab", "down":
+			if msg.String() == "down" {
+				m.lootFocusIndex++
+			} else {
+				m.lootFocusIndex--
+			}
+			cmds := updateFocusIndex(&m.lootFocusIndex, m.lootInputs)
+			return m, tea.Batch(cmds...)
+		}
+	}
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg, m.lootInputs)
+
+	return m, cmd
+}
+
+// Update loop for choosing who gets an item from the loot pool, and how many of the stack
+func updateGiveItem(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	i := slices.IndexFunc(m.party.Loot, func(item models.Item) bool { return item.ID == m.giveItemID })
+	if i < 0 || len(m.party.ActiveMembers) == 0 {
+		m.giveItemID = ""
+		return m, nil
+	}
+	item := m.party.Loot[i]
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "j", "down":
+			m.giveTarget = (m.giveTarget + 1) % len(m.party.ActiveMembers)
+		case "k", "up":
+			m.giveTarget = (m.giveTarget + len(m.party.ActiveMembers) - 1) % len(m.party.ActiveMembers)
+		case "l", "right", "+":
+			m.giveQuantity = min(m.giveQuantity+1, item.Quantity)
+		case "h", "left", "-":
+			m.giveQuantity = max(m.giveQuantity-1, 1)
+		case "enter":
+			member := m.party.ActiveMembers[m.giveTarget]
+			before := m.party.Clone()
+			tx, err := commands.AssignItem(&m.party, item.ID, member.ID, m.giveQuantity)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, tx.Type)
+			saveUpdateReset(&m, tx)
+			m.status = fmt.Sprintf("%s took %d %s", member.Name, m.giveQuantity, item.Name)
+			m.giveItemID = ""
+		case "n", "backspace":
+			m.giveItemID = ""
+		}
+	}
+	return m, nil
+}
+
+// Update loop for confirming the sale of an item, with how many, at what percentage and who gets the money
+func updateSellItem(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	item, ok := m.itemForSale()
+	if !ok {
+		m.sellItemID = ""
+		return m, nil
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "l", "right":
+			m.sellQuantity = min(m.sellQuantity+1, item.Quantity)
+		case "h", "left":
+			m.sellQuantity = max(m.sellQuantity-1, 1)
+		case "k", "up", "+":
+			m.salePercent = min(m.salePercent+5, 100)
+		case "j", "down", "-":
+			m.salePercent = max(m.salePercent-5, 0)
+		case "s":
+			m.sellKeep = !m.sellKeep && m.sellHolderID != ""
+		case "enter":
+			before := m.party.Clone()
+			opts := commands.SaleOptions{Percent: m.salePercent, Keep: m.sellKeep}
+			tx, err := commands.SellItem(&m.party, m.sellHolderID, item.ID, m.sellQuantity, opts)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			commands.Record(&m.history, &before, models.ItemSold)
+			saveUpdateReset(&m, tx)
+			sale := m.party.Sales[len(m.party.Sales)-1]
+			m.status = fmt.Sprintf("Sold %d %s for %s", sale.Quantity, sale.Item, formatCoins(sale.Coins))
+			m.sellItemID = ""
+		case "n", "backspace":
+			m.sellItemID = ""
+		}
+	}
+	return m, nil
+}
+
+// Update loop for creating, renaming, archiving and switching campaigns
+func updateCampaigns(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if m.campaignAction != "" {
+		return updateCampaignName(msg, m)
+	}
+
+	visible := m.visibleCampaigns()
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.status = ""
+		switch msg.String() {
+		case "j", "down":
+			if m.campaignCursor < len(visible)-1 {
+				m.campaignCursor++
+			}
+		case "k", "up":
+			if m.campaignCursor > 0 {
+				m.campaignCursor--
+			}
+		case "enter":
+			if len(visible) == 0 {
+				return m, nil
+			}
+			name := visible[m.campaignCursor].Name
+			if err := storage.UseCampaign(name); err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			if err := storage.SetLastCampaign(name); err != nil {
+				log.Printf("Couldn't remember campaign %s: %v", name, err)
+			}
+			m.loadCampaign()
+			m.status = "Switched to " + name
+			m.chosen = false
+		case "n":
+			m.campaignAction = "new"
+			m.campaignInput.Reset()
+			return m, m.campaignInput.Focus()
+		case "r":
+			if len(visible) == 0 {
+				return m, nil
+			}
+			m.campaignAction = "rename"
+			m.campaignInput.SetValue(visible[m.campaignCursor].Name)
+			return m, m.campaignInput.Focus()
+		case "a":
+			if len(visible) == 0 {
+				return m, nil
+			}
+			c := visible[m.campaignCursor]
+			if c.Name == storage.CurrentCampaign() && !c.Archived {
+				m.status = "Switch to another campaign before archiving this one"
+				return m, nil
+			}
+			if err := storage.SetArchived(c.Name, !c.Archived); err != nil {
+				m.status = err.Error()
+			}
+			m.refreshCampaigns()
+		case "h":
+			m.showArchived = !m.showArchived
+			m.campaignCursor = 0
+		case "l":
+			if len(visible) == 0 {
+				return m, nil
+			}
+			c := visible[m.campaignCursor]
+			leveling, label := models.MilestoneLeveling, "milestone"
+			if c.Leveling == models.MilestoneLeveling {
+				leveling, label = models.XPLeveling, "xp"
+			}
+			if err := storage.SetLeveling(c.Name, leveling); err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			m.refreshCampaigns()
+			m.refreshTables()
+			m.updateCampaignLevels(c.Name, "Leveling changed to "+label)
+		case "x":
+			if len(visible) == 0 {
+				return m, nil
+			}
+			c := visible[m.campaignCursor]
+			next := models.XPTables[0]
+			if c.XPTable != nil {
+				i := slices.IndexFunc(models.XPTables, func(t models.XPTable) bool { return t.Name == c.XPTable.Name })
+				next = models.XPTables[(i+1)%len(models.XPTables)]
+			}
+			if err := storage.SetXPTable(c.Name, next); err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			m.refreshCampaigns()
+			// The table isn't part of the party, so the switch isn't recorded for undo
+			m.updateCampaignLevels(c.Name, "XP table changed to "+next.Name)
+		case "backspace":
+			m.chosen = false
+		}
+	}
+
+	return m, nil
+}
+
+// Update loop for typing the name of a new or renamed campaign
+func updateCampaignName(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "enter" {
+		name := strings.TrimSpace(m.campaignInput.Value())
+		action := m.campaignAction
+		m.campaignAction = ""
+		m.campaignInput.Blur()
+		if name == "" {
+			return m, nil
+		}
+
+		var err error
+		if action == "new" {
+			err = storage.CreateCampaign(name)
+		} else {
+			err = storage.RenameCampaign(m.visibleCampaigns()[m.campaignCursor].Name, name)
+		}
+		if err != nil {
+			m.status = err.Error()
+		}
+		m.refreshCampaigns()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.campaignInput, cmd = m.campaignInput.Update(msg)
+	return m, cmd
+}
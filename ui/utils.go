@@ -1,9 +1,12 @@
 package ui
 
 import (
+	"dndgoldtracker/commands"
 	"dndgoldtracker/models"
 	"dndgoldtracker/storage"
 	"fmt"
+	"log"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -25,16 +28,15 @@ func checkbox(label string, checked bool) string {
 func membersToRows(members []models.Member) []table.Row {
 	var rows []table.Row
 	for _, m := range members {
-		rows = append(rows, table.Row{
-			m.Name,
-			strconv.Itoa(m.XP),
-			strconv.Itoa(m.Level),
-			strconv.Itoa(m.Coins[models.Platinum]),
-			strconv.Itoa(m.Coins[models.Gold]),
-			strconv.Itoa(m.Coins[models.Electrum]),
-			strconv.Itoa(m.Coins[models.Silver]),
-			strconv.Itoa(m.Coins[models.Copper]),
-		})
+		progress := strconv.Itoa(m.XP)
+		if models.UsesMilestones() {
+			progress = lastSession(m)
+		}
+		row := table.Row{m.Name, progress, strconv.Itoa(m.Level), formatShare(m.Share)}
+		for _, coinType := range models.CoinOrder {
+			row = append(row, strconv.Itoa(m.Coins[coinType]))
+		}
+		rows = append(rows, row)
 	}
 	return rows
 }
@@ -74,26 +76,65 @@ func configureInputs(placeholders []string) []textinput.Model {
 }
 
 func configureTable(members []models.Member) table.Model {
+	rows := membersToRows(members)
+
+	t := table.New(
+		table.WithColumns(memberColumns()),
+		table.WithRows(rows),
+		table.WithFocused(true),
+		table.WithHeight(5),
+	)
+	styleTable(&t)
+
+	return t
+}
+
+// Returns the inventory table columns, with item values in the currency's standard coin
+func inventoryColumns() []table.Column {
+	return []table.Column{
+		{Title: "Holder", Width: 10},
+		{Title: "Item", Width: 18},
+		{Title: "Qty", Width: 4},
+		{Title: strings.ToUpper(models.CurrentCurrency.StandardCoin().Abbr), Width: 6},
+		{Title: "Attuned", Width: 7},
+	}
+}
+
+// Returns the member table columns. Under milestone leveling the XP column shows
+// the session of each member's latest milestone instead.
+func memberColumns() []table.Column {
+	progress := table.Column{Title: xp, Width: 6}
+	if models.UsesMilestones() {
+		progress = table.Column{Title: session, Width: 7}
+	}
 	columns := []table.Column{
 		{Title: name, Width: 10},
-		{Title: xp, Width: 6},
+		progress,
 		{Title: level, Width: 6},
-		{Title: models.Platinum, Width: 10},
-		{Title: models.Gold, Width: 6},
-		{Title: models.Electrum, Width: 10},
-		{Title: models.Silver, Width: 8},
-		{Title: models.Copper, Width: 8},
+		{Title: share, Width: 6},
 	}
+	// A column for each coin in the campaign's currency, wide enough for its name
+	for _, coinType := range models.CoinOrder {
+		columns = append(columns, table.Column{Title: coinType, Width: max(len(coinType)+2, 6)})
+	}
+	return columns
+}
 
-	rows := membersToRows(members)
-
+// Builds the table listing the loot pool and then each member's items
+func configureInventoryTable(p models.Party) table.Model {
 	t := table.New(
-		table.WithColumns(columns),
-		table.WithRows(rows),
+		table.WithColumns(inventoryColumns()),
+		table.WithRows(inventoryToRows(inventoryEntries(p))),
 		table.WithFocused(true),
 		table.WithHeight(5),
 	)
+	styleTable(&t)
+
+	return t
+}
 
+// Gives a table the same header and selection styles as the member tables
+func styleTable(t *table.Model) {
 	s := table.DefaultStyles()
 	s.Header = s.Header.
 		BorderStyle(lipgloss.NormalBorder()).
@@ -105,8 +146,108 @@ func configureTable(members []models.Member) table.Model {
 		Background(lipgloss.Color("57")).
 		Bold(false)
 	t.SetStyles(s)
+}
 
-	return t
+// An item in the loot pool or a member's inventory
+type inventoryEntry struct {
+	holderID string // empty for the loot pool
+	holder   string
+	item     models.Item
+}
+
+// Lists the loot pool and then each member's items, in the order the inventory table shows them
+func inventoryEntries(p models.Party) []inventoryEntry {
+	var entries []inventoryEntry
+	for _, item := range p.Loot {
+		entries = append(entries, inventoryEntry{holder: models.LootName, item: item})
+	}
+	for _, member := range slices.Concat(p.ActiveMembers, p.InactiveMembers) {
+		for _, item := range member.Items {
+			entries = append(entries, inventoryEntry{holderID: member.ID, holder: member.Name, item: item})
+		}
+	}
+	return entries
+}
+
+func inventoryToRows(entries []inventoryEntry) []table.Row {
+	var rows []table.Row
+	for _, e := range entries {
+		attuned := ""
+		if e.item.Attuned {
+			attuned = "yes"
+		}
+		rows = append(rows, table.Row{e.holder, e.item.Name, strconv.Itoa(e.item.Quantity), strconv.Itoa(e.item.Value()), attuned})
+	}
+	return rows
+}
+
+// Finds the stack being sold in the loot pool or the seller's inventory
+func (m model) itemForSale() (models.Item, bool) {
+	items := m.party.Loot
+	if m.sellHolderID != "" {
+		i := commands.FindMember(m.party.ActiveMembers, m.sellHolderID)
+		if i < 0 {
+			return models.Item{}, false
+		}
+		items = m.party.ActiveMembers[i].Items
+	}
+	i := slices.IndexFunc(items, func(item models.Item) bool { return item.ID == m.sellItemID })
+	if i < 0 {
+		return models.Item{}, false
+	}
+	return items[i], true
+}
+
+// Returns the inventory entry in the table row under the cursor
+func selectedEntry(t table.Model, entries []inventoryEntry) (inventoryEntry, bool) {
+	i := t.Cursor()
+	if i < 0 || i >= len(entries) || len(t.SelectedRow()) == 0 {
+		return inventoryEntry{}, false
+	}
+	return entries[i], true
+}
+
+// Builds a read-only table showing what each member receives from a coin plan
+func planToTable(plan commands.CoinPlan) table.Model {
+	columns := []table.Column{{Title: name, Width: 10}}
+	for _, coinType := range models.CoinOrder {
+		columns = append(columns, table.Column{Title: coinType, Width: 10})
+	}
+
+	var rows []table.Row
+	if hasCoins(plan.Treasury) {
+		row := table.Row{models.TreasuryName}
+		for _, coinType := range models.CoinOrder {
+			row = append(row, strconv.Itoa(plan.Treasury[coinType]))
+		}
+		rows = append(rows, row)
+	}
+	for _, share := range plan.Shares {
+		row := table.Row{share.Name}
+		for _, coinType := range models.CoinOrder {
+			cell := strconv.Itoa(share.Coins[coinType])
+			if extra := share.Extra[coinType]; extra > 0 {
+				cell += fmt.Sprintf(" (+%d)", extra)
+			}
+			row = append(row, cell)
+		}
+		rows = append(rows, row)
+	}
+
+	return table.New(
+		table.WithColumns(columns),
+		table.WithRows(rows),
+		table.WithHeight(len(rows)+1),
+	)
+}
+
+// Returns the member in the table row under the cursor. Rows are built in member order.
+func selectedMember(t table.Model, members []models.Member) (models.Member, bool) {
+	i := t.Cursor()
+	if i < 0 || i >= len(members) || len(t.SelectedRow()) == 0 {
+		return models.Member{}, false
+	}
+	return members[i], true
 }
 
 func updateTableData(members []models.Member, t *table.Model) *table.Model {
@@ -115,6 +256,108 @@ func updateTableData(members []models.Member, t *table.Model) *table.Model {
 	return t
 }
 
+// Refreshes both member tables and the inventory table from the party.
+// The treasury is listed after the active members.
+func (m *model) refreshTables() {
+	// Clear the old rows first, they may have more cells than the new columns
+	// if the campaign switched to a currency with fewer coins
+	for _, t := range []*table.Model{&m.activeMemberTable, &m.inactiveMemberTable, &m.inventoryTable} {
+		t.SetRows(nil)
+	}
+	m.activeMemberTable.SetColumns(memberColumns())
+	m.inactiveMemberTable.SetColumns(memberColumns())
+	m.inventoryTable.SetColumns(inventoryColumns())
+	updateTableData(m.party.ActiveMembers, &m.activeMemberTable)
+	updateTableData(m.party.InactiveMembers, &m.inactiveMemberTable)
+	if hasCoins(m.party.Treasury) {
+		m.activeMemberTable.SetRows(append(m.activeMemberTable.Rows(), treasuryRow(m.party.Treasury)))
+	}
+	m.inventoryTable.SetRows(inventoryToRows(inventoryEntries(m.party)))
+}
+
+// Builds the table row for the party treasury, which has no XP, level or share
+func treasuryRow(treasury map[string]int) table.Row {
+	row := table.Row{models.TreasuryName, "", "", ""}
+	for _, coinType := range models.CoinOrder {
+		row = append(row, strconv.Itoa(treasury[coinType]))
+	}
+	return row
+}
+
+// Reports whether any coin in money is above zero
+func hasCoins(money map[string]int) bool {
+	for _, amount := range money {
+		if amount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Describes a purchase from its ledger entry, e.g. "Keg paid 1 Platinum and got 7 Gold back"
+func describePurchase(e models.LedgerEntry) string {
+	paid, change := make(map[string]int), make(map[string]int)
+	for coinType, diff := range e.Coins {
+		if diff < 0 {
+			paid[coinType] = -diff
+		} else {
+			change[coinType] = diff
+		}
+	}
+	s := e.Member + " paid " + formatCoins(paid)
+	if hasCoins(change) {
+		s += " and got " + formatCoins(change) + " back"
+	}
+	return s
+}
+
+// Returns the indexes of the active members giving and receiving a transfer,
+// making sure they're two different members when there are enough of them
+func (m model) transferPair() (int, int) {
+	n := len(m.party.ActiveMembers)
+	if n == 0 {
+		return 0, 0
+	}
+	from, to := m.transferFrom%n, m.transferTo%n
+	if to == from {
+		to = (to + 1) % n
+	}
+	return from, to
+}
+
+// Describes what members owe each other, one debt per line
+func (m model) debtLines() []string {
+	var lines []string
+	for _, d := range m.party.Debts {
+		lines = append(lines, fmt.Sprintf("%s owes %s %s", m.memberName(d.BorrowerID), m.memberName(d.LenderID), formatCoins(commands.CoinsWorth(d.Amount))))
+	}
+	return lines
+}
+
+// Looks up a member's name in either group
+func (m model) memberName(id string) string {
+	for _, member := range slices.Concat(m.party.ActiveMembers, m.party.InactiveMembers) {
+		if member.ID == id {
+			return member.Name
+		}
+	}
+	return id
+}
+
+// Lists the coins in money, e.g. "2 Gold, 15 Silver"
+func formatCoins(money map[string]int) string {
+	var parts []string
+	for _, coinType := range models.CoinOrder {
+		if amount := money[coinType]; amount != 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", amount, coinType))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
+
 func resetInputs(inputs []textinput.Model) {
 	for i := range inputs {
 		inputs[i].Reset()
@@ -145,16 +388,237 @@ func buildInputList(inputs []textinput.Model, focusIndex int, cursorMode cursor.
 
 func handleUnsetInputs(inputs []textinput.Model) {
 	for i := range inputs {
-		if inputs[i].Value() == "" {
+		if inputs[i].Value() == "" && inputs[i].Placeholder != reason && inputs[i].Placeholder != monsters {
 			inputs[i].SetValue("0")
 		}
 	}
 }
 
-func saveUpdateReset(m *model) {
-	storage.SaveParty(&m.party)
-	updateTableData(m.party.ActiveMembers, &m.activeMemberTable)
+// Returns the session of a member's latest milestone, or "" if it wasn't given
+func lastSession(member models.Member) string {
+	if len(member.Milestones) == 0 || member.Milestones[len(member.Milestones)-1].Session == 0 {
+		return ""
+	}
+	return strconv.Itoa(member.Milestones[len(member.Milestones)-1].Session)
+}
+
+// Returns the most recent milestones reached by any member, newest first
+func (m model) recentMilestones(count int) []string {
+	type reached struct {
+		member    string
+		milestone models.Milestone
+	}
+	var all []reached
+	for _, member := range slices.Concat(m.party.ActiveMembers, m.party.InactiveMembers) {
+		for _, milestone := range member.Milestones {
+			all = append(all, reached{member.Name, milestone})
+		}
+	}
+	slices.SortStableFunc(all, func(a, b reached) int { return b.milestone.Timestamp.Compare(a.milestone.Timestamp) })
+
+	var lines []string
+	for _, r := range all[:min(count, len(all))] {
+		line := fmt.Sprintf("%s reached Level %d", r.member, r.milestone.Level)
+		if r.milestone.Session > 0 {
+			line += fmt.Sprintf(" in session %d", r.milestone.Session)
+		}
+		if r.milestone.Reason != "" {
+			line += " (" + r.milestone.Reason + ")"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Returns the encounter for the monsters typed on the XP screen, or nil if none have been
+func (m model) encounter() (*commands.Encounter, error) {
+	text := inputValue(m.xpInputs, monsters)
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	groups, err := commands.ParseMonsters(text)
+	if err != nil {
+		return nil, err
+	}
+	ids := m.participantIDs()
+	e := commands.PlanEncounter(&m.party, groups, ids)
+	return &e, nil
+}
+
+// Returns the value of the input with the given placeholder, or "" if there isn't one
+func inputValue(inputs []textinput.Model, placeholder string) string {
+	for i := range inputs {
+		if inputs[i].Placeholder == placeholder {
+			return inputs[i].Value()
+		}
+	}
+	return ""
+}
+
+// Sets the value of the input with the given placeholder
+func setInputValue(inputs []textinput.Model, placeholder string, value string) {
+	for i := range inputs {
+		if inputs[i].Placeholder == placeholder {
+			inputs[i].SetValue(value)
+		}
+	}
+}
+
+// Formats a share weight without trailing zeros, treating an unset share as a full one
+func formatShare(s float64) string {
+	if s <= 0 {
+		s = models.FullShare
+	}
+	return strconv.FormatFloat(s, 'f', -1, 64)
+}
+
+// Saves the party and undo history and records the transactions in the ledger
+func saveParty(m *model, txs ...models.Transaction) {
+	if err := storage.Commit(&m.party, &m.history, txs...); err != nil {
+		log.Printf("Failed to save: %v", err)
+	}
+}
+
+func saveUpdateReset(m *model, tx models.Transaction) {
+	saveParty(m, tx)
+	m.refreshTables()
 	resetInputs(m.coinInputs)
+	resetInputs(m.xpInputs)
+	resetInputs(m.milestoneInputs)
+	resetInputs(m.memberInputs)
+	resetInputs(m.spendInputs)
+	resetInputs(m.transferInputs)
+	resetInputs(m.treasuryInputs)
+	resetInputs(m.lootInputs)
+	m.excluded = nil
+	m.claims = nil
+}
+
+// Works a campaign's levels out again after its leveling or XP table changed,
+// through the loaded party if it's the current campaign
+func (m *model) updateCampaignLevels(name string, reason string) {
+	change := func(p *models.Party) models.Transaction {
+		tx := commands.UpdateLevels(p)
+		tx.Reason = reason
+		return tx
+	}
+	if name == storage.CurrentCampaign() {
+		saveUpdateReset(m, change(&m.party))
+		return
+	}
+	if _, err := storage.UpdateCampaignParty(name, change); err != nil {
+		m.status = err.Error()
+	}
+}
+
+// Returns the IDs of the active members picked to share the next award,
+// or nil if nobody has been left out
+func (m model) participantIDs() []string {
+	if len(m.excluded) == 0 {
+		return nil
+	}
+	ids := []string{}
+	for _, member := range m.party.ActiveMembers {
+		if !m.excluded[member.ID] {
+			ids = append(ids, member.ID)
+		}
+	}
+	return ids
+}
+
+// Describes who shares the next award
+func (m model) participantsHelp() string {
+	ids := m.participantIDs()
+	if ids == nil {
+		return "Shared by all active members" + helpStyle.Render(" (ctrl+p to choose)")
+	}
+	var names []string
+	for _, member := range m.party.ActiveMembers {
+		if slices.Contains(ids, member.ID) {
+			names = append(names, member.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"nobody"}
+	}
+	return "Shared by " + focusedStyle.Render(strings.Join(names, ", ")) + helpStyle.Render(" (ctrl+p to choose)")
+}
+
+// Summarises the items claimed for the next coin award
+func (m model) claimsHelp() string {
+	if len(m.claims) == 0 {
+		return "No items taken as part of a share" + helpStyle.Render(" (ctrl+l to choose)")
+	}
+	var claimed []string
+	for _, item := range m.party.Loot {
+		if c, ok := m.claims[item.ID]; ok {
+			claimed = append(claimed, fmt.Sprintf("%s takes %d %s", m.memberName(c.MemberID), c.Quantity, item.Name))
+		}
+	}
+	return focusedStyle.Render(strings.Join(claimed, ", ")) + helpStyle.Render(" (ctrl+l to change)")
+}
+
+// Returns the claims for the next coin award in loot pool order
+func (m model) claimList() []commands.ItemClaim {
+	var claims []commands.ItemClaim
+	for _, item := range m.party.Loot {
+		if c, ok := m.claims[item.ID]; ok {
+			claims = append(claims, c)
+		}
+	}
+	return claims
+}
+
+// Explains how a member's items and coins add up to their share of a haul
+func describeAllocation(share commands.MemberShare) string {
+	s := share.Name + ": share " + models.CurrentCurrency.Format(share.FairValue) + ", gets "
+	if len(share.Items) > 0 {
+		var items []string
+		for _, item := range share.Items {
+			items = append(items, fmt.Sprintf("%d %s", item.Quantity, item.Name))
+		}
+		s += strings.Join(items, ", ") + " worth " + models.CurrentCurrency.Format(share.ItemValue) + " and "
+	}
+	s += models.CurrentCurrency.Format(commands.CoinValue(share.Coins)) + " in coins"
+	if over := share.ItemValue - share.FairValue; over > 0 {
+		s += ", " + models.CurrentCurrency.Format(over) + " over"
+	}
+	return s
+}
+
+// Describes the undo and redo keys along with the action each would reverse
+func undoHelp(h models.History) string {
+	help := "u: undo"
+	if len(h.Undo) > 0 {
+		help += " " + h.Undo[len(h.Undo)-1].Action
+	}
+	help += ", r: redo"
+	if len(h.Redo) > 0 {
+		help += " " + h.Redo[len(h.Redo)-1].Action
+	}
+	return help
+}
+
+// Reloads the campaign list from disk
+func (m *model) refreshCampaigns() {
+	campaigns, err := storage.ListCampaigns()
+	if err != nil {
+		log.Printf("Couldn't list campaigns: %v", err)
+		m.status = "Couldn't list campaigns"
+	}
+	m.campaigns = campaigns
+	m.campaignCursor = min(m.campaignCursor, max(len(m.visibleCampaigns())-1, 0))
+}
+
+// Returns the campaigns shown in the switcher, hiding archived ones unless asked for
+func (m model) visibleCampaigns() []models.Campaign {
+	var visible []models.Campaign
+	for _, c := range m.campaigns {
+		if !c.Archived || m.showArchived {
+			visible = append(visible, c)
+		}
+	}
+	return visible
 }
 
 func changeCursorMode(inputs []textinput.Model, cursorMode *cursor.Mode) []tea.Cmd {
@@ -193,3 +657,18 @@ func updateFocusIndex(focusIndex *int, inputs []textinput.Model) []tea.Cmd {
 
 	return cmds
 }
+
+// Returns the member under the cursor of whichever member table has focus
+func (m model) focusedMember() (models.Member, bool) {
+	if m.activeMemberTable.Focused() {
+		return selectedMember(m.activeMemberTable, m.party.ActiveMembers)
+	}
+	return selectedMember(m.inactiveMemberTable, m.party.InactiveMembers)
+}
+
+// Saves an edit or deletion along with any group changes still waiting to be saved
+func (m *model) saveMemberChange(tx models.Transaction) {
+	saveParty(m, append(m.pendingTransactions, tx)...)
+	m.pendingTransactions = nil
+	m.refreshTables()
+}
@@ -2,61 +2,255 @@ package ui
 
 import (
 	"dndgoldtracker/commands"
+	"dndgoldtracker/models"
+	"dndgoldtracker/storage"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
 )
 
 // Sub-Views
 
+// The view shown when the saved party couldn't be loaded
+func recoveryView(m model) string {
+	var msg strings.Builder
+	msg.WriteString(focusedStyle.Render("The party for campaign "+storage.CurrentCampaign()+" couldn't be loaded.") + "\n")
+	msg.WriteString(m.loadErr.Error() + "\n\n")
+
+	if errors.Is(m.loadErr, storage.ErrNewerVersion) {
+		msg.WriteString("It was saved by a newer version, so it's left as it is. Update dndgoldtracker to open it.\n\n")
+		msg.WriteString(subtleStyle.Render("q, esc: quit"))
+		return msg.String()
+	}
+	if m.restore != nil {
+		msg.WriteString("The most recent valid backup is from " + m.restore.Created.Format(time.DateTime) + ".\n\n")
+		msg.WriteString(subtleStyle.Render("y: restore the backup") + dotStyle)
+	} else {
+		msg.WriteString("No valid backup was found.\n\n")
+	}
+	msg.WriteString(subtleStyle.Render("n: start a new party, keeping the damaged file") + dotStyle +
+		subtleStyle.Render("q, esc: quit"))
+	return msg.String()
+}
+
+// The banner shown after an XP award, listing who went up a level and what they gain
+func levelUpView(m model) string {
+	var msg strings.Builder
+	msg.WriteString(focusedStyle.Render("🎉 Level up! 🎉") + "\n")
+	for _, e := range m.levelUps {
+		msg.WriteString(fmt.Sprintf("\n%s: Level %d → %s\n", e.Member, e.From, focusedStyle.Render(strconv.Itoa(e.To))))
+		for _, change := range e.Changes() {
+			msg.WriteString("  • " + change + "\n")
+		}
+	}
+	s := bannerStyle.Render(msg.String()) + "\n\n"
+	return s + subtleStyle.Render("any key: continue") + dotStyle + subtleStyle.Render("q, esc: quit")
+}
+
 // The first view, where you're choosing a task
 func choicesView(m model) string {
 	choice := m.choice
-	var msg string
+	msg := "Campaign: " + focusedStyle.Render(storage.CurrentCampaign()) + "\n"
 	if (len(m.party.ActiveMembers)) > 0 {
 		msg += "Current Coin Priority is to " +
 			focusedStyle.Render(m.party.ActiveMembers[commands.GetFirstCoinPriority(&m.party)].Name)
 	}
 
-	msg += baseStyle.Render(m.activeMemberTable.View())
+	// Show what everyone is carrying next to the party once there's any loot
+	if len(m.inventoryTable.Rows()) > 0 {
+		msg += lipgloss.JoinHorizontal(lipgloss.Top, baseStyle.Render(m.activeMemberTable.View()), baseStyle.Render(m.inventoryTable.View()))
+	} else {
+		msg += baseStyle.Render(m.activeMemberTable.View())
+	}
 
 	msg += "\nWhat would you like to do?"
 	msg += "\n"
 
-	msg += fmt.Sprintf(
-		"\n%s\n%s\n%s\n%s\n",
-		checkbox("Distribute Money", choice == 0),
-		checkbox("Distribute Experience", choice == 1),
-		checkbox("Add Member", choice == 2),
-		checkbox("Activate/Deactivate Party Members", choice == 3),
-	)
+	msg += "\n"
+	for i, label := range choiceLabels {
+		if i == choiceExperience && models.UsesMilestones() {
+			label = "Level Up by Milestone"
+		}
+		msg += checkbox(label, choice == i) + "\n"
+	}
 
 	msg += subtleStyle.Render("j/k, up/down: select") + dotStyle +
 		subtleStyle.Render("enter: choose") + dotStyle +
+		subtleStyle.Render(undoHelp(m.history)) + dotStyle +
 		subtleStyle.Render("q, esc: quit")
 
+	if m.status != "" {
+		msg += "\n" + focusedStyle.Render(m.status)
+	}
+
 	return msg
 }
 
 // The view for adding money
 func moneyView(m model) string {
+	if m.coinPlan != nil {
+		return coinPlanView(m)
+	}
+	if m.picking {
+		return participantsView(m)
+	}
+	if m.claiming {
+		return claimsView(m)
+	}
+
 	var msg strings.Builder
 
-	msg.WriteString(baseStyle.Render("Money entered here will be distributed to all party members as equally as possible.\n" +
+	msg.WriteString(baseStyle.Render("Money entered here will be distributed to all party members by their share as equally as possible.\n" +
 		"Extra coins are distributed based on a priority system that rotates.\n"))
 	if (len(m.party.ActiveMembers)) > 0 {
 		msg.WriteString("Current Coin Priority is to " +
 			focusedStyle.Render(m.party.ActiveMembers[commands.GetFirstCoinPriority(&m.party)].Name))
 	}
 
+	msg.WriteString("\nSplit " + focusedStyle.Render(strategyLabels[m.coinStrategy]) + helpStyle.Render(" (ctrl+t to change)") + "\n")
+	msg.WriteString(m.participantsHelp() + "\n")
+	if len(m.party.Loot) > 0 {
+		msg.WriteString(m.claimsHelp() + "\n")
+	}
+	if len(m.party.Debts) > 0 {
+		settle := "no"
+		if m.settleDebts {
+			settle = "yes"
+		}
+		msg.WriteString("Pay back loans out of this award: " + focusedStyle.Render(settle) + helpStyle.Render(" (ctrl+s to change)") + "\n")
+	}
 	msg.WriteString("\n" + buildInputList(m.coinInputs, m.coinFocusIndex, m.cursorMode))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for confirming a planned coin distribution
+func coinPlanView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("Each member will receive the following. Coins in brackets are extras from the priority rotation.\n")
+	msg.WriteString(baseStyle.Render(planToTable(*m.coinPlan).View()))
+	for _, e := range m.coinPlan.Exchanges {
+		msg.WriteString("\n" + subtleStyle.Render(e.String()))
+	}
+	for _, s := range m.coinPlan.Settlements {
+		msg.WriteString("\n" + s.Borrower + " pays back " + formatCoins(s.Coins) + " to " + s.Lender)
+	}
+	if m.coinPlan.ClaimsItems() {
+		msg.WriteString("\nItems count against each share of the coins and items together")
+		for _, share := range m.coinPlan.Shares {
+			msg.WriteString("\n  " + describeAllocation(share))
+		}
+	}
+	msg.WriteString("\n" + subtleStyle.Render("y, enter: accept") + dotStyle +
+		subtleStyle.Render("n, backspace: cancel"))
 	return msg.String()
 }
 
 // The view for adding experience
 func xpView(m model) string {
+	if m.picking {
+		return participantsView(m)
+	}
+	if models.UsesMilestones() {
+		return milestoneView(m)
+	}
+
 	var msg strings.Builder
-	msg.WriteString("Xp entered here will be distributed to all party members equally\n\n")
+	msg.WriteString("Xp entered here will be distributed to all party members by their share\n")
+	msg.WriteString("Or list the monsters defeated to award the XP they're worth instead\n")
+	msg.WriteString(m.participantsHelp() + "\n")
+	msg.WriteString(encounterPreview(m) + "\n")
 	msg.WriteString(buildInputList(m.xpInputs, m.xpFocusIndex, m.cursorMode))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for levelling up members who reached a milestone
+func milestoneView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("This campaign uses milestone leveling, everyone chosen goes up a level\n")
+	msg.WriteString(m.participantsHelp() + "\n")
+	if recent := m.recentMilestones(5); len(recent) > 0 {
+		msg.WriteString(subtleStyle.Render("Recent milestones:\n  "+strings.Join(recent, "\n  ")) + "\n")
+	}
+	msg.WriteString("\n" + buildInputList(m.milestoneInputs, m.milestoneFocusIndex, m.cursorMode))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// Describes the encounter typed on the XP screen and how hard it was for the party
+func encounterPreview(m model) string {
+	multiplier := "no"
+	if m.multiplyEncounter {
+		multiplier = "yes"
+	}
+	line := "Apply the group size multiplier to the award: " + focusedStyle.Render(multiplier) + helpStyle.Render(" (ctrl+e to change)") + "\n"
+
+	e, err := m.encounter()
+	switch {
+	case err != nil:
+		return line + subtleStyle.Render(err.Error()) + "\n"
+	case e == nil:
+		return line
+	}
+	var thresholds []string
+	for i, difficulty := range models.Difficulties {
+		thresholds = append(thresholds, fmt.Sprintf("%s %d", difficulty, e.Thresholds[i]))
+	}
+	return line + fmt.Sprintf("Encounter: %d XP, x%g for %d XP adjusted, ", e.BaseXP, e.Multiplier, e.AdjustedXP) +
+		focusedStyle.Render(e.Difficulty) + "\n" +
+		subtleStyle.Render("Party thresholds: "+strings.Join(thresholds, ", ")) + "\n" +
+		"Awarding " + focusedStyle.Render(strconv.Itoa(e.Award(m.multiplyEncounter))+" XP") + "\n"
+}
+
+// The view for picking which active members share the next award
+func participantsView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("Who shares this award?\n\n")
+	for i, member := range m.party.ActiveMembers {
+		cursor := "  "
+		if i == m.pickerCursor {
+			cursor = focusedStyle.Render("> ")
+		}
+		msg.WriteString(cursor + checkbox(member.Name, !m.excluded[member.ID]) + "\n")
+	}
+	msg.WriteString(subtleStyle.Render("\nup/down: select") + dotStyle +
+		subtleStyle.Render("space: include/leave out") + dotStyle +
+		subtleStyle.Render("a: everyone") + dotStyle +
+		subtleStyle.Render("enter: done"))
+	return msg.String()
+}
+
+// The view for choosing who takes which loot pool items as part of their share
+func claimsView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("Who takes which items as part of their share?\n\n")
+	for i, item := range m.party.Loot {
+		cursor := "  "
+		if i == m.claimCursor {
+			cursor = focusedStyle.Render("> ")
+		}
+		taker := "nobody"
+		if c, ok := m.claims[item.ID]; ok {
+			taker = focusedStyle.Render(fmt.Sprintf("%s takes %d", m.memberName(c.MemberID), c.Quantity))
+		}
+		msg.WriteString(fmt.Sprintf("%s%s (%d, %d %s each): %s\n", cursor, item.Name, item.Quantity, item.ValueGP, models.CurrentCurrency.StandardCoin().Abbr, taker))
+	}
+	msg.WriteString(subtleStyle.Render("\nup/down: select") + dotStyle +
+		subtleStyle.Render("left/right: who takes it") + dotStyle +
+		subtleStyle.Render("+/-: how many") + dotStyle +
+		subtleStyle.Render("enter: done"))
 	return msg.String()
 }
 
@@ -83,7 +277,279 @@ func activateMemberView(m model) string {
 
 	msg.WriteString(subtleStyle.Render("\nup/down: select") + dotStyle +
 		subtleStyle.Render("enter: activate/deactivate member") + dotStyle +
+		subtleStyle.Render("e: edit") + dotStyle +
+		subtleStyle.Render("d: delete") + dotStyle +
 		subtleStyle.Render("s: save and return to menu") + dotStyle +
 		subtleStyle.Render("tab: switch table"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+func editMemberView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("Edit the party member's name, XP, share and coins\n")
+	msg.WriteString(buildInputList(m.editInputs, m.editFocusIndex, m.cursorMode))
+	msg.WriteString("\n" + subtleStyle.Render("ctrl+x: cancel"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+func deleteMemberView(m model) string {
+	var msg strings.Builder
+	for _, member := range slices.Concat(m.party.ActiveMembers, m.party.InactiveMembers) {
+		if member.ID == m.deleteMemberID {
+			msg.WriteString("Delete " + member.Name + " for good?\n\n")
+		}
+	}
+	msg.WriteString("Their coins can be split among the active members, put in the treasury or leave with them.\n")
+	msg.WriteString("Their items go back to the loot pool either way.\n\n")
+	msg.WriteString(subtleStyle.Render("s: delete and split wallet") + dotStyle +
+		subtleStyle.Render("t: delete and put wallet in treasury") + dotStyle +
+		subtleStyle.Render("x: delete and discard wallet") + dotStyle +
+		subtleStyle.Render("n: cancel"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for recording a purchase
+func spendView(m model) string {
+	var msg strings.Builder
+	if len(m.party.ActiveMembers) == 0 {
+		msg.WriteString("There are no active members to spend coins\n\n")
+	} else {
+		spender := m.party.ActiveMembers[min(m.spender, len(m.party.ActiveMembers)-1)]
+		msg.WriteString(focusedStyle.Render(spender.Name) + " spends" + helpStyle.Render(" (ctrl+n to change)") + "\n")
+		msg.WriteString("Wallet: " + formatCoins(spender.Coins) + "\n\n")
+	}
+	msg.WriteString("Enter the price. Change is handed back if the exact coins aren't in the wallet.\n")
+	msg.WriteString(buildInputList(m.spendInputs, m.spendFocusIndex, m.cursorMode))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for moving coins between two members
+func transferView(m model) string {
+	var msg strings.Builder
+	if len(m.party.ActiveMembers) < 2 {
+		msg.WriteString("Transfers need at least two active members\n\n")
+	} else {
+		from, to := m.transferPair()
+		giver, receiver := m.party.ActiveMembers[from], m.party.ActiveMembers[to]
+		msg.WriteString(focusedStyle.Render(giver.Name) + " gives " + focusedStyle.Render(receiver.Name) +
+			" a " + focusedStyle.Render(transferLabels[m.transferKind]) + "\n")
+		msg.WriteString(helpStyle.Render("ctrl+n: change giver"+dotChar+"ctrl+o: change recipient"+dotChar+"ctrl+l: gift, loan or repayment") + "\n")
+		msg.WriteString("Wallet: " + formatCoins(giver.Coins) + "\n\n")
+	}
+	if lines := m.debtLines(); len(lines) > 0 {
+		msg.WriteString("Outstanding debts\n")
+		for _, line := range lines {
+			msg.WriteString(subtleStyle.Render("  "+line) + "\n")
+		}
+		msg.WriteString("\n")
+	}
+	msg.WriteString(buildInputList(m.transferInputs, m.transferFocusIndex, m.cursorMode))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for the loot pool and member inventories
+func lootView(m model) string {
+	if m.addingLoot {
+		return addLootView(m)
+	}
+	if m.giveItemID != "" {
+		return giveItemView(m)
+	}
+	if m.sellItemID != "" {
+		return sellItemView(m)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("Loot and Inventory\n")
+	msg.WriteString(baseStyle.Render(m.inventoryTable.View()))
+	if m.showSales {
+		msg.WriteString("\n" + salesHistory(m.party.Sales))
+	} else if selected, ok := selectedEntry(m.inventoryTable, inventoryEntries(m.party)); ok {
+		item := selected.item
+		details := fmt.Sprintf("%s: %d %s and %s lb each", item.Name, item.ValueGP, models.CurrentCurrency.StandardCoin().Abbr, strconv.FormatFloat(item.Weight, 'f', -1, 64))
+		if item.Notes != "" {
+			details += dotChar + item.Notes
+		}
+		msg.WriteString("\n" + details)
+	} else {
+		msg.WriteString("\nThe party hasn't found anything yet")
+	}
+
+	msg.WriteString(subtleStyle.Render("\nup/down: select") + dotStyle +
+		subtleStyle.Render("a: add loot") + dotStyle +
+		subtleStyle.Render("g, enter: give to a member") + dotStyle +
+		subtleStyle.Render("r: return to the loot pool") + dotStyle +
+		subtleStyle.Render("t: attune/end attunement") + dotStyle +
+		subtleStyle.Render("s: sell") + dotStyle +
+		subtleStyle.Render("h: show/hide sales") + dotStyle +
+		subtleStyle.Render("backspace: back to menu"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for entering a newly found item
+func addLootView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("Add an item to the loot pool. Value (in " + models.CurrentCurrency.StandardCoin().Abbr + ") and weight are for one of the items.\n")
+	msg.WriteString(buildInputList(m.lootInputs, m.lootFocusIndex, m.cursorMode))
+	msg.WriteString("\n" + subtleStyle.Render("ctrl+x: cancel"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for choosing who gets an item from the loot pool
+func giveItemView(m model) string {
+	var msg strings.Builder
+	for _, item := range m.party.Loot {
+		if item.ID == m.giveItemID {
+			msg.WriteString(fmt.Sprintf("Give %s of %d %s to\n\n", focusedStyle.Render(strconv.Itoa(m.giveQuantity)), item.Quantity, item.Name))
+		}
+	}
+	for i, member := range m.party.ActiveMembers {
+		label := member.Name
+		if attuned := commands.AttunedCount(member); attuned > 0 {
+			label += fmt.Sprintf(" (attuned to %d)", attuned)
+		}
+		msg.WriteString(checkbox(label, i == m.giveTarget) + "\n")
+	}
+	msg.WriteString(subtleStyle.Render("\nup/down: select") + dotStyle +
+		subtleStyle.Render("left/right: how many") + dotStyle +
+		subtleStyle.Render("enter: give") + dotStyle +
+		subtleStyle.Render("n, backspace: cancel"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// The view for confirming the sale of an item
+func sellItemView(m model) string {
+	item, ok := m.itemForSale()
+	if !ok {
+		return "That item is gone"
+	}
+	seller := models.LootName
+	if m.sellHolderID != "" {
+		seller = m.memberName(m.sellHolderID)
+	}
+
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Sell %s of %d %s from %s\n", focusedStyle.Render(strconv.Itoa(m.sellQuantity)), item.Quantity, item.Name, seller))
+	msg.WriteString(fmt.Sprintf("at %s of the listed %d %s each for %s\n", focusedStyle.Render(strconv.Itoa(m.salePercent)+"%"), item.ValueGP, models.CurrentCurrency.StandardCoin().Abbr,
+		focusedStyle.Render(formatCoins(commands.SalePrice(item, m.sellQuantity, m.salePercent)))))
+	if m.sellKeep {
+		msg.WriteString("The money goes to " + focusedStyle.Render(seller) + "\n")
+	} else {
+		msg.WriteString("The money is " + focusedStyle.Render("split among the party") + "\n")
+	}
+
+	msg.WriteString(subtleStyle.Render("\nleft/right: how many") + dotStyle +
+		subtleStyle.Render("up/down: percentage") + dotStyle)
+	if m.sellHolderID != "" {
+		msg.WriteString(subtleStyle.Render("s: split or keep the money") + dotStyle)
+	}
+	msg.WriteString(subtleStyle.Render("enter: sell") + dotStyle +
+		subtleStyle.Render("n, backspace: cancel"))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+// Lists the most recent sales, newest first
+func salesHistory(sales []models.Sale) string {
+	if len(sales) == 0 {
+		return "Nothing has been sold yet"
+	}
+	var msg strings.Builder
+	msg.WriteString("Sales\n")
+	for i := len(sales) - 1; i >= max(len(sales)-5, 0); i-- {
+		s := sales[i]
+		from, to := "by "+s.Seller, "split among the party"
+		if s.SellerID == "" {
+			from = "from the loot pool"
+		}
+		if s.Kept {
+			to = "kept by " + s.Seller
+		}
+		msg.WriteString(subtleStyle.Render(fmt.Sprintf("  %s: %d %s sold %s at %d%% for %s, %s",
+			s.Timestamp.Format(time.DateOnly), s.Quantity, s.Item, from, s.Percent, formatCoins(s.Coins), to)) + "\n")
+	}
+	return msg.String()
+}
+
+// The view for depositing into or withdrawing from the party treasury
+func treasuryView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("The treasury holds " + focusedStyle.Render(formatCoins(m.party.Treasury)) + "\n\n")
+	if m.treasuryTarget == 0 || m.treasuryTarget > len(m.party.ActiveMembers) {
+		msg.WriteString("Deposit into the treasury")
+	} else {
+		msg.WriteString("Withdraw to " + focusedStyle.Render(m.party.ActiveMembers[m.treasuryTarget-1].Name))
+	}
+	msg.WriteString(helpStyle.Render(" (ctrl+n to change)") + "\n\n")
+	msg.WriteString(buildInputList(m.treasuryInputs, m.treasuryFocusIndex, m.cursorMode))
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
+	return msg.String()
+}
+
+func campaignsView(m model) string {
+	var msg strings.Builder
+	msg.WriteString("Campaigns\n\n")
+	for i, c := range m.visibleCampaigns() {
+		label := c.Name
+		if c.Name == storage.CurrentCampaign() {
+			label += " (current)"
+		}
+		if c.Archived {
+			label += " (archived)"
+		}
+		if c.Leveling == models.MilestoneLeveling {
+			label += " (milestone leveling)"
+		}
+		if c.XPTable != nil {
+			label += " (" + c.XPTable.Name + " XP table)"
+		}
+		msg.WriteString(checkbox(label, i == m.campaignCursor) + "\n")
+	}
+
+	if m.campaignAction != "" {
+		msg.WriteString("\n" + m.campaignInput.View() + "\n")
+		msg.WriteString(subtleStyle.Render("enter: confirm, leave empty to cancel"))
+	} else {
+		msg.WriteString(subtleStyle.Render("\nup/down: select") + dotStyle +
+			subtleStyle.Render("enter: switch") + dotStyle +
+			subtleStyle.Render("n: new") + dotStyle +
+			subtleStyle.Render("r: rename") + dotStyle +
+			subtleStyle.Render("a: archive/restore") + dotStyle +
+			subtleStyle.Render("h: show/hide archived") + dotStyle +
+			subtleStyle.Render("l: XP/milestone leveling") + dotStyle +
+			subtleStyle.Render("x: next XP table") + dotStyle +
+			subtleStyle.Render("backspace: back to menu"))
+	}
+	if m.status != "" {
+		msg.WriteString("\n" + focusedStyle.Render(m.status))
+	}
 	return msg.String()
 }